@@ -0,0 +1,470 @@
+// Command regexcrossword fetches, inspects, and solves puzzles from
+// regexcrossword.com.
+//
+// Usage:
+//
+//	regexcrossword fetch [-base url] [-timeout duration] challenges|puzzles
+//	regexcrossword sync [-base url] [-timeout duration] dir
+//	regexcrossword validate [file ...]
+//	regexcrossword ops [file ...]
+//	regexcrossword solve [-n limit] [file ...]
+//	regexcrossword show [file ...]
+//	regexcrossword difficulty [-tiers file] [file ...]
+//	regexcrossword minimize [file ...]
+//
+// Commands that read puzzles read the named files, or standard input
+// if none are named. Each input is JSON holding a puzzle, an array of
+// puzzles, or an array of challenges, as written by fetch, or a
+// snapshot, as saved by sync. Files named with the extension .txt
+// instead hold a single puzzle in the text format of
+// crossword.ParsePuzzle.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/regexp-crossword/crossword"
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+const usage = `usage: regexcrossword <command> [arguments]
+
+Commands:
+	fetch [-base url] [-timeout duration] challenges|puzzles
+	                          write challenges or player puzzles as JSON
+	sync [-base url] [-timeout duration] dir
+	                          save a snapshot of the corpus in dir
+	validate [file ...]       report patterns that do not parse or cannot
+	                          match their lines
+	ops [file ...]            count the regexp ops used by patterns
+	solve [-n limit] [file ...]
+	                          print solutions to puzzles
+	show [file ...]           print the patterns of puzzles
+	difficulty [-tiers file] [file ...]
+	                          estimate the difficulty of puzzles
+	minimize [file ...]       print puzzles with minimal patterns, as text
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command in args and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var cmd func(args []string, stdin io.Reader, stdout io.Writer) error
+	switch args[0] {
+	case "fetch":
+		cmd = fetch
+	case "sync":
+		cmd = sync
+	case "validate":
+		cmd = validate
+	case "ops":
+		cmd = ops
+	case "solve":
+		cmd = solve
+	case "show":
+		cmd = show
+	case "difficulty":
+		cmd = difficulty
+	case "minimize":
+		cmd = minimize
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "regexcrossword: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	if err := cmd(args[1:], stdin, stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(stderr, "regexcrossword %s: %v\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// clientFlags adds the flags for fetching from the site to fs.
+func clientFlags(fs *flag.FlagSet) (base *string, timeout *time.Duration) {
+	base = fs.String("base", crossword.DefaultBaseURL, "fetch from the site at `url`")
+	timeout = fs.Duration("timeout", time.Minute, "give up after `duration`")
+	return base, timeout
+}
+
+func fetch(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	base, timeout := clientFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("want challenges or puzzles")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := &crossword.Client{BaseURL: *base}
+	var v interface{}
+	var err error
+	switch fs.Arg(0) {
+	case "challenges":
+		v, err = c.GetChallenges(ctx)
+	case "puzzles":
+		v, err = c.GetPlayerPuzzles(ctx)
+	default:
+		return fmt.Errorf("unknown kind %q: want challenges or puzzles", fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+func sync(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	base, timeout := clientFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("want a store directory")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	s, err := crossword.OpenStore(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	snap, diff, err := s.Sync(ctx, &crossword.Client{BaseURL: *base})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "snapshot %s\n", snap.Time.Format(time.RFC3339))
+	for _, group := range []struct {
+		name string
+		ids  []string
+	}{{"added", diff.Added}, {"changed", diff.Changed}, {"unpublished", diff.Unpublished}} {
+		for _, id := range group.ids {
+			fmt.Fprintf(stdout, "%s %s\n", group.name, id)
+		}
+	}
+	return nil
+}
+
+// errInvalid reports that validate found invalid patterns, which it
+// has already printed.
+type errInvalid int
+
+func (e errInvalid) Error() string {
+	return fmt.Sprintf("%d patterns are invalid", int(e))
+}
+
+func validate(args []string, stdin io.Reader, stdout io.Writer) error {
+	puzzles, err := readPuzzles(args, stdin)
+	if err != nil {
+		return err
+	}
+	// Group the patterns by error, in order of first appearance.
+	var msgs []string
+	grouped := make(map[string][]string)
+	n := 0
+	for _, p := range puzzles {
+		for _, err := range p.ValidatePatterns() {
+			msg := err.Err.Error()
+			if _, ok := grouped[msg]; !ok {
+				msgs = append(msgs, msg)
+			}
+			grouped[msg] = append(grouped[msg], err.Pattern)
+			n++
+		}
+	}
+	for i, msg := range msgs {
+		if i != 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout, msg)
+		for _, pattern := range grouped[msg] {
+			fmt.Fprintln(stdout, pattern)
+		}
+	}
+	if n != 0 {
+		return errInvalid(n)
+	}
+	return nil
+}
+
+func ops(args []string, stdin io.Reader, stdout io.Writer) error {
+	puzzles, err := readPuzzles(args, stdin)
+	if err != nil {
+		return err
+	}
+	counts := make(map[syntax.Op]int)
+	for _, p := range puzzles {
+		p.PatternOps(counts)
+	}
+	var keys []syntax.Op
+	for op := range counts {
+		keys = append(keys, op)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, op := range keys {
+		fmt.Fprintf(stdout, "%-16s %d\n", op, counts[op])
+	}
+	return nil
+}
+
+func solve(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("solve", flag.ContinueOnError)
+	limit := fs.Int("n", 1, "print up to `limit` solutions to each puzzle, or all if negative")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	puzzles, err := readPuzzles(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i, p := range puzzles {
+		if i != 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout, title(&p))
+		grids, err := p.Solutions(*limit)
+		if err == nil && len(grids) == 0 && *limit != 0 {
+			err = crossword.ErrNoSolution
+		}
+		if err != nil {
+			fmt.Fprintln(stdout, err)
+			failed++
+			continue
+		}
+		for j, grid := range grids {
+			if j != 0 {
+				fmt.Fprintln(stdout)
+			}
+			for _, row := range grid {
+				fmt.Fprintln(stdout, string(row))
+			}
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d puzzles not solved", failed, len(puzzles))
+	}
+	return nil
+}
+
+func show(args []string, stdin io.Reader, stdout io.Writer) error {
+	puzzles, err := readPuzzles(args, stdin)
+	if err != nil {
+		return err
+	}
+	for i, p := range puzzles {
+		if i != 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout, title(&p))
+		if p.Hexagonal {
+			fmt.Fprintf(stdout, "hexagonal, size %d\n", p.Size)
+		}
+		if len(p.Characters) != 0 {
+			key := "characters"
+			if p.CellCharacters {
+				key = "cell characters"
+			}
+			fmt.Fprintf(stdout, "%s: %s\n", key, strings.Join(p.Characters, " "))
+		}
+		for _, axis := range []struct {
+			name     string
+			patterns [][]string
+		}{{"x", p.PatternsX}, {"y", p.PatternsY}, {"z", p.PatternsZ}} {
+			for side, patterns := range axis.patterns {
+				for j, pattern := range patterns {
+					fmt.Fprintf(stdout, "%s%d.%d\t%s\n", axis.name, side, j, pattern)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func difficulty(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("difficulty", flag.ContinueOnError)
+	tiersFile := fs.String("tiers", "", "suggest tiers calibrated on the challenges in `file` instead of the default tiers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var tiers crossword.Tiers
+	if *tiersFile != "" {
+		challenges, err := readChallenges(*tiersFile)
+		if err != nil {
+			return err
+		}
+		tiers = crossword.CalibrateTiers(challenges)
+	}
+	puzzles, err := readPuzzles(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	for _, p := range puzzles {
+		d := p.Difficulty()
+		if d.Err != nil {
+			fmt.Fprintf(stdout, "%s\t%v\n", title(&p), d.Err)
+			continue
+		}
+		tier := d.Tier
+		if tiers != nil {
+			tier = tiers.Tier(d.Score)
+		}
+		fmt.Fprintf(stdout, "%s\t%.1f\t%s\t%.2f\n", title(&p), d.Score, tier, p.RatingAvg)
+	}
+	return nil
+}
+
+func minimize(args []string, stdin io.Reader, stdout io.Writer) error {
+	puzzles, err := readPuzzles(args, stdin)
+	if err != nil {
+		return err
+	}
+	for i, p := range puzzles {
+		if i != 0 {
+			fmt.Fprintln(stdout)
+		}
+		q, err := p.Minimize()
+		if err != nil {
+			return fmt.Errorf("%s: %v", title(&p), err)
+		}
+		if err := q.WriteText(stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// title identifies a puzzle by its ID and name.
+func title(p *crossword.Puzzle) string {
+	if p.Name == "" {
+		return p.ID
+	}
+	return p.ID + " " + p.Name
+}
+
+// readPuzzles reads the puzzles in the named files, or in stdin if
+// there are none.
+func readPuzzles(files []string, stdin io.Reader) ([]crossword.Puzzle, error) {
+	if len(files) == 0 {
+		return decodePuzzles(stdin)
+	}
+	var puzzles []crossword.Puzzle
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		var p []crossword.Puzzle
+		if strings.HasSuffix(name, ".txt") {
+			var q *crossword.Puzzle
+			if q, err = crossword.ParsePuzzle(f); err == nil {
+				p = []crossword.Puzzle{*q}
+			}
+		} else {
+			p, err = decodePuzzles(f)
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		puzzles = append(puzzles, p...)
+	}
+	return puzzles, nil
+}
+
+// readChallenges reads the named file, which holds an array of
+// challenges or a snapshot.
+func readChallenges(name string) ([]crossword.Challenge, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var raw json.RawMessage
+	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	var snap struct {
+		Challenges []crossword.Challenge `json:"challenges"`
+	}
+	if err := json.Unmarshal(raw, &snap); err == nil {
+		return snap.Challenges, nil
+	}
+	var challenges []crossword.Challenge
+	if err := json.Unmarshal(raw, &challenges); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return challenges, nil
+}
+
+// decodePuzzles decodes a puzzle, an array of puzzles, an array of
+// challenges, or a snapshot.
+func decodePuzzles(r io.Reader) ([]crossword.Puzzle, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var snap struct {
+		Challenges []crossword.Challenge `json:"challenges"`
+		Puzzles    []crossword.Puzzle    `json:"puzzles"`
+	}
+	if err := json.Unmarshal(raw, &snap); err == nil && snap.Challenges != nil {
+		puzzles := snap.Puzzles
+		for _, c := range snap.Challenges {
+			puzzles = append(puzzles, c.Puzzles...)
+		}
+		return puzzles, nil
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		elems = []json.RawMessage{raw}
+	}
+	var puzzles []crossword.Puzzle
+	for _, elem := range elems {
+		var probe struct {
+			Puzzles json.RawMessage `json:"puzzles"`
+		}
+		if err := json.Unmarshal(elem, &probe); err != nil {
+			return nil, err
+		}
+		if probe.Puzzles != nil {
+			var c crossword.Challenge
+			if err := json.Unmarshal(elem, &c); err != nil {
+				return nil, err
+			}
+			puzzles = append(puzzles, c.Puzzles...)
+			continue
+		}
+		var p crossword.Puzzle
+		if err := json.Unmarshal(elem, &p); err != nil {
+			return nil, err
+		}
+		puzzles = append(puzzles, p)
+	}
+	return puzzles, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const puzzleJSON = `{"id":"p1","name":"Tiny","patternsX":[["[^SPEAK]+","EP|IP|EF"]],"patternsY":[["HE|LL|O+","[PLEASE]+"]]}`
+
+func TestDecodePuzzles(t *testing.T) {
+	for _, input := range []string{
+		puzzleJSON,
+		`[` + puzzleJSON + `,` + puzzleJSON + `]`,
+		`[{"id":"c1","puzzles":[` + puzzleJSON + `]},{"id":"c2","puzzles":[` + puzzleJSON + `]}]`,
+		`{"time":"2020-01-01T00:00:00Z","challenges":[],"puzzles":[` + puzzleJSON + `]}`,
+	} {
+		puzzles, err := decodePuzzles(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if len(puzzles) == 0 || puzzles[0].ID != "p1" || len(puzzles[0].PatternsY[0]) != 2 {
+			t.Errorf("%s: got %+v", input, puzzles)
+		}
+	}
+	if _, err := decodePuzzles(strings.NewReader(`[1]`)); err == nil {
+		t.Error("expected error for non-object element")
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, test := range []struct {
+		Args   []string
+		Input  string
+		Status int
+		Output string
+	}{
+		{[]string{"solve"}, puzzleJSON, 0, "p1 Tiny\nHE\nLP\n"},
+		{[]string{"validate"}, puzzleJSON, 0, ""},
+		{[]string{"validate"}, `{"patternsX":[["a(","b"]]}`, 1, "error parsing regexp: missing closing ): `a(`\na(\n"},
+		{[]string{"validate"}, `{"patternsX":[["a","b"]],"patternsY":[["abc"]]}`, 1, "matches no string of the length of its line\nabc\n"},
+		{[]string{"ops"}, `{"patternsX":[["ab|c"]]}`, 0, "Literal          2\nAlternate        1\n"},
+		{[]string{"show"}, puzzleJSON, 0, "p1 Tiny\nx0.0\t[^SPEAK]+\nx0.1\tEP|IP|EF\ny0.0\tHE|LL|O+\ny0.1\t[PLEASE]+\n"},
+		{[]string{"difficulty"}, `{"id":"p2","patternsX":[["a("]],"patternsY":[["a"]]}`, 0, "p2\tcrossword: pattern a(: error parsing regexp: missing closing ): `a(`\n"},
+		{[]string{"minimize"}, puzzleJSON, 0, "id: p1\nname: Tiny\ncharacters:\n\tAEFHIKLOPS\nx:\n\t[^P]+\n\t.P\ny:\n\tHE\n\t[LP]+\n"},
+		{[]string{"frob"}, "", 2, ""},
+		{nil, "", 2, ""},
+	} {
+		var stdout, stderr bytes.Buffer
+		status := run(test.Args, strings.NewReader(test.Input), &stdout, &stderr)
+		if status != test.Status || stdout.String() != test.Output {
+			t.Errorf("%q: got status %d, output %q, want %d, %q (stderr %q)",
+				test.Args, status, stdout.String(), test.Status, test.Output, stderr.String())
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/puzzles" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `[`+puzzleJSON+`]`)
+	}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"fetch", "-base", srv.URL, "puzzles"}, nil, &stdout, &stderr); status != 0 {
+		t.Fatalf("fetch puzzles: status %d: %s", status, stderr.String())
+	}
+	puzzles, err := decodePuzzles(&stdout)
+	if err != nil || len(puzzles) != 1 || puzzles[0].ID != "p1" {
+		t.Errorf("fetch puzzles: got %+v, %v", puzzles, err)
+	}
+	stderr.Reset()
+	if status := run([]string{"fetch", "-base", srv.URL, "challenges"}, nil, &stdout, &stderr); status != 1 || !strings.Contains(stderr.String(), "404") {
+		t.Errorf("fetch challenges: got status %d, stderr %q", status, stderr.String())
+	}
+}
+
+func TestReadTextPuzzle(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"solve", "../../crossword/testdata/mit.txt"}, nil, &stdout, &stderr)
+	if status != 0 || !strings.HasPrefix(stdout.String(), "mit A Regular Crossword\nNHPEHAS\n") {
+		t.Errorf("got status %d, output %q (stderr %q)", status, stdout.String(), stderr.String())
+	}
+}
+
+func TestDifficultyTiers(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "challenges.json")
+	challenges := `[{"name":"Easy","puzzles":[{"patternsX":[["A","B"]],"patternsY":[["AB"]]}]},` +
+		`{"name":"Harder","puzzles":[` + puzzleJSON + `]}]`
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, challenges)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"difficulty"}, strings.NewReader(puzzleJSON), &stdout, &stderr)
+	if fields := strings.Split(stdout.String(), "\t"); status != 0 || len(fields) != 4 || fields[2] == "" {
+		t.Errorf("default tiers: got status %d, output %q (stderr %q)", status, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	status = run([]string{"difficulty", "-tiers", name}, strings.NewReader(puzzleJSON), &stdout, &stderr)
+	if fields := strings.Split(stdout.String(), "\t"); status != 0 || len(fields) != 4 || fields[2] != "Harder" {
+		t.Errorf("got status %d, output %q (stderr %q)", status, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if status := run([]string{"difficulty", "-tiers", name + ".missing"}, strings.NewReader(puzzleJSON), &stdout, &stderr); status != 1 {
+		t.Errorf("missing tiers file: got status %d", status)
+	}
+}
@@ -0,0 +1,126 @@
+package crossword
+
+import (
+	"fmt"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+// CheckResult is the verdict on a filled grid.
+type CheckResult struct {
+	Solved   bool           // every pattern matches its line
+	Patterns []PatternCheck // in the order of the layout's lines, then sides
+	Err      error          // the puzzle is invalid or the grid has the wrong shape
+}
+
+// PatternCheck is the verdict on a single pattern.
+type PatternCheck struct {
+	Axis    Axis
+	Line    int // index of the line on its axis
+	Side    int // index of the pattern among the line's patterns
+	Pattern string
+	Cells   [][2]int // row and column of each cell, in reading order
+	Text    string   // the runes of the cells, in reading order
+	Match   bool
+
+	// Prefix is the length, in runes, of the longest prefix of Text
+	// that begins some string of the line's length that the pattern
+	// matches, with the rest of the string drawn from the puzzle's
+	// alphabet. When the pattern does not match, the rune at Prefix
+	// is the first that violates it. Prefix is len(Text) for a match.
+	Prefix int
+}
+
+// Check grades a filled grid against every pattern of the puzzle.
+// Lines are matched in full over the syntax tree by the matcher that
+// the solver checks complete lines with, so that Check accepts exactly
+// the grids that Solve and Solutions may return. Blank patterns match
+// any line.
+func (p *Puzzle) Check(grid [][]rune) CheckResult {
+	s, err := newSolver(p)
+	if err != nil {
+		return CheckResult{Err: err}
+	}
+	rows := s.layout.Rows
+	if len(grid) != len(rows) {
+		return CheckResult{Err: fmt.Errorf("crossword: grid has %d rows, want %d", len(grid), len(rows))}
+	}
+	var cells []rune
+	for r, row := range grid {
+		if len(row) != rows[r] {
+			return CheckResult{Err: fmt.Errorf("crossword: row %d of grid has %d cells, want %d", r, len(row), rows[r])}
+		}
+		cells = append(cells, row...)
+	}
+
+	res := CheckResult{Solved: true}
+	init := append([]uint64(nil), s.doms...)
+	for i, l := range s.layout.Lines {
+		pats := s.lines[i].patterns
+		text := make([]rune, len(l.Cells))
+		pos := make([][2]int, len(l.Cells))
+		for j, cell := range l.Cells {
+			text[j] = cells[cell]
+			pos[j] = [2]int{s.layout.Cells[cell].Row, s.layout.Cells[cell].Col}
+		}
+		for side, expr := range l.Patterns {
+			pc := PatternCheck{
+				Axis:    l.Axis,
+				Line:    l.Index,
+				Side:    side,
+				Pattern: expr,
+				Cells:   pos,
+				Text:    string(text),
+				Match:   true,
+				Prefix:  len(text),
+			}
+			if expr != "" {
+				pat := pats[0]
+				pats = pats[1:]
+				if !syntax.MatchRunes(pat.re, text) {
+					pc.Match = false
+					pc.Prefix = s.prefix(pat, l.Cells, text)
+					copy(s.doms, init)
+				}
+			}
+			res.Solved = res.Solved && pc.Match
+			res.Patterns = append(res.Patterns, pc)
+		}
+	}
+	return res
+}
+
+// Failed returns the checks of the patterns that do not match.
+func (r CheckResult) Failed() []PatternCheck {
+	var failed []PatternCheck
+	for _, pc := range r.Patterns {
+		if !pc.Match {
+			failed = append(failed, pc)
+		}
+	}
+	return failed
+}
+
+// prefix returns the length of the longest prefix of text that the
+// pattern can be completed from, fixing the cells one at a time and
+// checking that the pattern still supports the domains. The pattern
+// must not match text in full.
+func (s *solver) prefix(pat *pattern, cells []int, text []rune) int {
+	for n, cell := range cells {
+		i := s.index(text[n])
+		if i < 0 {
+			return n
+		}
+		d := s.dom(cell)
+		for j := range d {
+			d[j] = 0
+		}
+		d[i/64] = 1 << uint(i%64)
+		if _, ok := pat.supports(s, cells); !ok {
+			return n
+		}
+	}
+	// Only a pattern too complex for the exact supports gets here,
+	// and the last rune is where it must fail.
+	return len(cells) - 1
+}
@@ -0,0 +1,108 @@
+package crossword
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`A+B+A`, `(B|C)\1*`, `[AB]*`}, {`.*`, `.C.`, `(A|B)B\1`}},
+		PatternsY: [][]string{{`(.).\1`, `[^A]C.`, `A.*`}},
+	}
+	res := p.Check(runeGrid("ACA", "BCB", "ACA"))
+	if res.Err != nil || !res.Solved {
+		t.Fatalf("solution: got solved %t, error %v", res.Solved, res.Err)
+	}
+	if len(res.Patterns) != 9 {
+		t.Fatalf("got %d patterns, want 9", len(res.Patterns))
+	}
+
+	res = p.Check(runeGrid("ACB", "BCB", "ACA"))
+	if res.Err != nil || res.Solved {
+		t.Fatalf("wrong grid: got solved %t, error %v", res.Solved, res.Err)
+	}
+	want := []PatternCheck{
+		{AxisY, 0, 0, `(.).\1`, [][2]int{{0, 0}, {0, 1}, {0, 2}}, "ACB", false, 2},
+		{AxisX, 2, 1, `(A|B)B\1`, [][2]int{{0, 2}, {1, 2}, {2, 2}}, "BBA", false, 2},
+	}
+	if got := res.Failed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got failures\n%+v\nwant\n%+v", got, want)
+	}
+
+	res = p.Check(runeGrid("ZZZ", "BCB", "ACA"))
+	for _, pc := range res.Failed() {
+		if pc.Prefix != 0 {
+			t.Errorf("%#q: got prefix %d for rune outside the alphabet, want 0", pc.Pattern, pc.Prefix)
+		}
+	}
+
+	if res := p.Check(runeGrid("AC", "BC", "AC")); res.Err == nil {
+		t.Error("expected error for short rows")
+	}
+}
+
+func TestCheckAtomic(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`B?+A`, `(?>B+)`}},
+		PatternsY: [][]string{{`A*+B.*`, `(?>A|BA)B`}},
+	}
+	if res := p.Check(runeGrid("BB", "AB")); res.Err != nil || !res.Solved {
+		t.Fatalf("solution: got solved %t, error %v", res.Solved, res.Err)
+	}
+	res := p.Check(runeGrid("AB", "BB"))
+	var failed []string
+	for _, pc := range res.Failed() {
+		failed = append(failed, pc.Pattern)
+	}
+	if want := []string{`(?>A|BA)B`, `B?+A`}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("got failures %q, want %q", failed, want)
+	}
+}
+
+// TestCheckAgreesWithSolve checks that Check rejects a grid that only
+// a matcher that allows empty iterations of (A*)+ would accept.
+func TestCheckAgreesWithSolve(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{"A", "B"}},
+		PatternsY: [][]string{{`(A*)+B\1`}},
+	}
+	if grid, err := p.Solve(); err == nil {
+		t.Fatalf("Solve: got %q, want no solution", gridStrings(grid))
+	}
+	if res := p.Check(runeGrid("AB")); res.Err != nil || res.Solved {
+		t.Errorf("got solved %t, error %v, want unsolved", res.Solved, res.Err)
+	}
+}
+
+func TestCheckMIT(t *testing.T) {
+	grid := runeGrid(mitSolution...)
+	if res := mitPuzzle.Check(grid); res.Err != nil || !res.Solved {
+		t.Fatalf("got solved %t, error %v", res.Solved, res.Err)
+	}
+	grid[6][6] = 'X'
+	res := mitPuzzle.Check(grid)
+	if res.Solved {
+		t.Fatal("altered grid is solved")
+	}
+	for _, pc := range res.Failed() {
+		if pc.Prefix >= len([]rune(pc.Text)) {
+			t.Errorf("%#q: got prefix %d for failing line %q", pc.Pattern, pc.Prefix, pc.Text)
+		}
+		found := false
+		for _, c := range pc.Cells {
+			found = found || c == [2]int{6, 6}
+		}
+		if !found {
+			t.Errorf("%#q fails but does not cross the altered cell", pc.Pattern)
+		}
+	}
+}
+
+func runeGrid(rows ...string) [][]rune {
+	grid := make([][]rune, len(rows))
+	for i, row := range rows {
+		grid[i] = []rune(row)
+	}
+	return grid
+}
@@ -0,0 +1,83 @@
+package crossword
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DefaultBaseURL is the base URL of the regexcrossword.com site.
+const DefaultBaseURL = "https://regexcrossword.com"
+
+// DefaultClient is the Client used by GetChallenges and
+// GetPlayerPuzzles.
+var DefaultClient = &Client{}
+
+// A Client fetches puzzles from a regexcrossword.com site.
+type Client struct {
+	// BaseURL is the scheme and host of the site, optionally with
+	// a path prefix. If empty, DefaultBaseURL is used.
+	BaseURL string
+
+	// HTTPClient sends the requests. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+}
+
+// StatusError is returned when the site responds with a status other
+// than 200 OK.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string // e.g. "404 Not Found"
+}
+
+func (e *StatusError) Error() string {
+	return "crossword: GET " + e.URL + ": " + e.Status
+}
+
+// GetChallenges fetches all default challenges.
+func (c *Client) GetChallenges(ctx context.Context) ([]Challenge, error) {
+	var challenges []Challenge
+	if err := c.get(ctx, "/data/challenges.json", &challenges); err != nil {
+		return nil, err
+	}
+	return challenges, nil
+}
+
+// GetPlayerPuzzles fetches all user-submitted puzzles.
+func (c *Client) GetPlayerPuzzles(ctx context.Context) ([]Puzzle, error) {
+	var puzzles []Puzzle
+	if err := c.get(ctx, "/api/puzzles", &puzzles); err != nil {
+		return nil, err
+	}
+	return puzzles, nil
+}
+
+// get fetches the JSON document at path and decodes it into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	url := strings.TrimSuffix(base, "/") + path
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{URL: url, StatusCode: res.StatusCode, Status: res.Status}
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,52 @@
+package crossword
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testPuzzleJSON = `{"id":"p1","name":"Tiny","patternsX":[["[^SPEAK]+","EP|IP|EF"]],"patternsY":[["HE|LL|O+","[PLEASE]+"]],"dateUpdated":1500000000}`
+
+// newTestClient returns a client for a stand-in for the site, which
+// serves one challenge and one player puzzle.
+func newTestClient(t *testing.T) *Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/challenges.json", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"id":"c1","name":"Beginner","puzzles":[`+testPuzzleJSON+`]}]`)
+	})
+	mux.HandleFunc("/api/puzzles", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[`+testPuzzleJSON+`]`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+}
+
+func TestClientStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>down</html>", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL + "/", HTTPClient: srv.Client()}
+	_, err := c.GetPlayerPuzzles(context.Background())
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("got error %v, want *StatusError", err)
+	}
+	if se.StatusCode != http.StatusServiceUnavailable || se.URL != srv.URL+"/api/puzzles" {
+		t.Errorf("got %+v", se)
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetChallenges(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,8 +1,8 @@
 package crossword
 
 import (
-	"encoding/json"
-	"net/http"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
@@ -38,54 +38,109 @@ type Puzzle struct {
 	Votes       int64      `json:"votes"`
 	Solved      UnixTime   `json:"solved"`
 	Ambiguous   bool       `json:"ambiguous"`
+
+	// Solution holds the rows of the solution for puzzles authored
+	// locally. The site does not publish solutions.
+	Solution []string `json:"solution,omitempty"`
+
+	// CellCharacters, for puzzles authored locally, means that
+	// Characters holds one string for each cell, in row-major order,
+	// rather than the runes allowed in every cell.
+	CellCharacters bool `json:"cellCharacters,omitempty"`
 }
 
-// GetChallenges fetches all default challenges.
+// GetChallenges fetches all default challenges from regexcrossword.com
+// with DefaultClient.
 func GetChallenges() ([]Challenge, error) {
-	res, err := http.Get("https://regexcrossword.com/data/challenges.json")
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-	var c []Challenge
-	if err := json.NewDecoder(res.Body).Decode(&c); err != nil {
-		return nil, err
-	}
-	return c, nil
+	return DefaultClient.GetChallenges(context.Background())
 }
 
-// GetPlayerPuzzles fetches all user-submitted puzzles.
+// GetPlayerPuzzles fetches all user-submitted puzzles from
+// regexcrossword.com with DefaultClient.
 func GetPlayerPuzzles() ([]Puzzle, error) {
-	res, err := http.Get("https://regexcrossword.com/api/puzzles")
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-	var p []Puzzle
-	if err := json.NewDecoder(res.Body).Decode(&p); err != nil {
-		return nil, err
-	}
-	return p, nil
+	return DefaultClient.GetPlayerPuzzles(context.Background())
 }
 
-// SyntaxError is a pattern parse error.
-type SyntaxError struct {
+// parseFlags are the flags used to parse patterns. The site matches
+// patterns as JavaScript regular expressions.
+const parseFlags = syntax.Perl | syntax.Backref | syntax.Lookaround | syntax.Atomic | syntax.PermissiveEscapes
+
+// PatternError is an error in a pattern: a parse error, or, from
+// ValidatePatterns, ErrUnmatchable or an error constraining the
+// pattern to the length of its line.
+type PatternError struct {
 	Pattern string
 	Err     error
 }
 
-// ValidatePatterns parses each pattern and reports syntax errors.
-func (p *Puzzle) ValidatePatterns() []SyntaxError {
-	var errs []SyntaxError
+func (e *PatternError) Error() string {
+	return "crossword: pattern " + e.Pattern + ": " + e.Err.Error()
+}
+
+func (e *PatternError) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnmatchable is reported by ValidatePatterns for a pattern that
+// matches no string of the length of its line.
+var ErrUnmatchable = errors.New("matches no string of the length of its line")
+
+// ValidatePatterns parses each pattern and reports parse errors. If
+// the puzzle can be laid out, it also reports ErrUnmatchable for each
+// pattern that matches no string of the length of its line over the
+// puzzle's alphabet, which would otherwise surface only as a puzzle
+// without solutions. Patterns with backreferences, lookarounds or
+// atomic groups are checked with syntax.Count, which is exact unless
+// they match too many strings of the approximations that it bounds
+// them by, in which case they go unreported.
+func (p *Puzzle) ValidatePatterns() []PatternError {
+	var errs []PatternError
 	for _, axis := range [3][][]string{p.PatternsX, p.PatternsY, p.PatternsZ} {
 		for _, set := range axis {
 			for _, pattern := range set {
-				if _, err := syntax.Parse(pattern, syntax.Perl|syntax.Backref|syntax.PermissiveEscapes); err != nil {
-					errs = append(errs, SyntaxError{pattern, err})
+				if _, err := syntax.Parse(pattern, parseFlags); err != nil {
+					errs = append(errs, PatternError{pattern, err})
 				}
 			}
 		}
 	}
+	l, err := p.Layout()
+	if err != nil {
+		return errs
+	}
+	runes, err := p.alphabet()
+	if err != nil {
+		return errs
+	}
+	class := runeClass(runes)
+	for _, line := range l.Lines {
+		n := len(line.Cells)
+		for _, pattern := range line.Patterns {
+			if pattern == "" {
+				continue
+			}
+			re, err := syntax.Parse(pattern, parseFlags)
+			if err != nil {
+				continue
+			}
+			re = re.Simplify()
+			var empty bool
+			if needsTree(re) {
+				count, exact := syntax.Count(re, n, runes)
+				empty = exact && count.Sign() == 0
+			} else {
+				sized, err := syntax.ConstrainLength(re.Mask(class), n, n+1)
+				if err != nil {
+					errs = append(errs, PatternError{pattern, err})
+					continue
+				}
+				empty, _ = syntax.IsEmpty(sized.Size(n))
+			}
+			if empty {
+				errs = append(errs, PatternError{pattern, ErrUnmatchable})
+			}
+		}
+	}
 	return errs
 }
 
@@ -93,7 +148,7 @@ func (p *Puzzle) PatternOps(counts map[syntax.Op]int) {
 	for _, axis := range [3][][]string{p.PatternsX, p.PatternsY, p.PatternsZ} {
 		for _, set := range axis {
 			for _, pattern := range set {
-				re, err := syntax.Parse(pattern, syntax.Perl|syntax.Backref|syntax.PermissiveEscapes)
+				re, err := syntax.Parse(pattern, parseFlags)
 				if err != nil {
 					continue
 				}
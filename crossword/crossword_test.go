@@ -1,77 +1,126 @@
 package crossword
 
 import (
-	"fmt"
+	"context"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
 )
 
 func TestGetChallenges(t *testing.T) {
-	if _, err := GetChallenges(); err != nil {
+	c := newTestClient(t)
+	challenges, err := c.GetChallenges(context.Background())
+	if err != nil {
 		t.Fatal(err)
 	}
+	if len(challenges) != 1 || challenges[0].ID != "c1" || len(challenges[0].Puzzles) != 1 {
+		t.Errorf("got %+v", challenges)
+	}
 }
 
 func TestGetPlayerPuzzles(t *testing.T) {
-	if _, err := GetPlayerPuzzles(); err != nil {
+	c := newTestClient(t)
+	puzzles, err := c.GetPlayerPuzzles(context.Background())
+	if err != nil {
 		t.Fatal(err)
 	}
+	if len(puzzles) != 1 || puzzles[0].ID != "p1" || puzzles[0].PatternsX[0][1] != "EP|IP|EF" {
+		t.Errorf("got %+v", puzzles)
+	}
 }
 
-func TestValidatePatterns(t *testing.T) {
-	challenges, err := GetChallenges()
-	if err != nil {
-		t.Fatal(err)
+// testSnapshot loads the latest snapshot from the store named by the
+// REGEXCROSSWORD_STORE environment variable, as saved by
+// "regexcrossword sync", or skips the test if it is not set.
+func testSnapshot(t *testing.T) *Snapshot {
+	dir := os.Getenv("REGEXCROSSWORD_STORE")
+	if dir == "" {
+		t.Skip("REGEXCROSSWORD_STORE not set")
 	}
-	puzzles, err := GetPlayerPuzzles()
+	snap, err := (&Store{Dir: dir}).Latest()
 	if err != nil {
 		t.Fatal(err)
 	}
-	var errs []SyntaxError
-	for _, c := range challenges {
+	return snap
+}
+
+func TestValidatePatterns(t *testing.T) {
+	snap := testSnapshot(t)
+	var errs []PatternError
+	for _, c := range snap.Challenges {
 		for _, p := range c.Puzzles {
 			errs = append(errs, p.ValidatePatterns()...)
 		}
 	}
-	for _, p := range puzzles {
+	for _, p := range snap.Puzzles {
 		errs = append(errs, p.ValidatePatterns()...)
 	}
-	if len(errs) != 0 {
-		t.Fail()
-	}
 	grouped := make(map[string][]string)
 	for _, err := range errs {
 		e := err.Err.Error()
 		grouped[e] = append(grouped[e], err.Pattern)
 	}
 	for err, patterns := range grouped {
-		fmt.Println(err)
-		for _, p := range patterns {
-			fmt.Println(p)
-		}
-		fmt.Println()
+		t.Errorf("%s:\n%s", err, strings.Join(patterns, "\n"))
 	}
 }
 
-func TestOpUsage(t *testing.T) {
-	challenges, err := GetChallenges()
-	if err != nil {
-		t.Fatal(err)
+func TestValidatePatternsUnmatchable(t *testing.T) {
+	p := &Puzzle{
+		PatternsX: [][]string{{"A+", "[^ABC]+", ""}},
+		PatternsY: [][]string{{"A.", "ABC|A", "^A..$"}},
 	}
-	puzzles, err := GetPlayerPuzzles()
-	if err != nil {
-		t.Fatal(err)
+	var got []string
+	for _, err := range p.ValidatePatterns() {
+		if err.Err != ErrUnmatchable {
+			t.Errorf("ValidatePatterns() = error %v", err)
+			continue
+		}
+		got = append(got, err.Pattern)
 	}
+	// The alphabet is A, B and C, which [^ABC] excludes.
+	want := []string{"A.", "[^ABC]+"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("ValidatePatterns() reported %q, want %q", got, want)
+	}
+}
+
+func TestValidatePatternsBackref(t *testing.T) {
+	for _, test := range []struct {
+		X           string
+		Rows        int
+		Unmatchable bool
+	}{
+		{`(?:(A)|B)\1`, 1, false},
+		{`(A)\1`, 1, true},
+		{`(A)\1`, 2, false},
+		{`(?=B)A`, 1, true},
+		{`(?>A|B)B`, 2, false},
+		{`(?>A+)A`, 2, true},
+	} {
+		p := &Puzzle{PatternsX: [][]string{{test.X}}, PatternsY: [][]string{make([]string, test.Rows)}}
+		for i := range p.PatternsY[0] {
+			p.PatternsY[0][i] = `[AB]`
+		}
+		errs := p.ValidatePatterns()
+		if got := len(errs) != 0; got != test.Unmatchable {
+			t.Errorf("ValidatePatterns() for %#q in %d rows = %v, want unmatchable %t", test.X, test.Rows, errs, test.Unmatchable)
+		}
+	}
+}
+
+func TestOpUsage(t *testing.T) {
+	snap := testSnapshot(t)
 	counts := make(map[syntax.Op]int)
-	for _, c := range challenges {
+	for _, c := range snap.Challenges {
 		for _, p := range c.Puzzles {
 			p.PatternOps(counts)
 		}
 	}
-	for _, p := range puzzles {
+	for _, p := range snap.Puzzles {
 		p.PatternOps(counts)
 	}
-	t.Fail()
-	fmt.Println(counts)
+	t.Log(counts)
 }
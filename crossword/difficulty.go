@@ -0,0 +1,217 @@
+package crossword
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+// DifficultyReport estimates how hard a puzzle is to solve by hand.
+type DifficultyReport struct {
+	Score float64 // higher is harder
+	Tier  string  // the nearest of DefaultTiers
+
+	Ops           map[syntax.Op]int // as counted by PatternOps
+	Patterns      int               // nonblank patterns
+	Cells         int
+	PatternLength float64 // mean runes per pattern
+
+	// LineBits is the mean, over patterns, of the bits needed to
+	// choose a string for the pattern's line from those that the
+	// pattern alone matches, as counted by syntax.Count over the runes
+	// that the line's cells may hold. For a pattern with
+	// backreferences, lookarounds or atomic groups and many matches,
+	// the count is an upper bound.
+	LineBits float64
+
+	// Propagated is the fraction of cells fixed by propagating the
+	// patterns, before any search, and Guesses the number of
+	// candidates that search then tried to find up to two solutions.
+	Propagated float64
+	Guesses    int
+	Solutions  int // 0, 1, or 2 for more than one
+
+	// Err is set, and the other fields but Ops left zero, if the
+	// grid cannot be laid out or a pattern does not parse or
+	// compile. A puzzle without solutions has no error.
+	Err error
+}
+
+// opWeights are the weights in the score of each use of an op.
+// Unlisted ops weigh 1.
+var opWeights = map[syntax.Op]float64{
+	syntax.OpLiteral:        0.5,
+	syntax.OpAnyCharNotNL:   0.5,
+	syntax.OpAnyChar:        0.5,
+	syntax.OpCapture:        0.5,
+	syntax.OpConcat:         0,
+	syntax.OpAlternate:      1.5,
+	syntax.OpStar:           1.5,
+	syntax.OpPlus:           1.5,
+	syntax.OpQuest:          1.5,
+	syntax.OpRepeat:         2,
+	syntax.OpWordBoundary:   2,
+	syntax.OpNoWordBoundary: 2,
+	syntax.OpBackref:        4,
+	syntax.OpLookahead:      3,
+	syntax.OpNegLookahead:   3,
+	syntax.OpLookbehind:     3,
+	syntax.OpNegLookbehind:  3,
+	syntax.OpAtomic:         3,
+}
+
+// Difficulty estimates the difficulty of the puzzle. The score adds
+// up, per pattern, the weighted ops and the length of the pattern,
+// the bits of freedom per cell left by the patterns alone, the
+// size of the grid, and the share of cells that propagation leaves to
+// search and the guesses that search makes. A puzzle that has more
+// than one solution, or none, is harder than its score says.
+//
+// The weights are rough. Scores are meaningful only relative to each
+// other, as when ordering puzzles or placing them in Tiers calibrated
+// on the site's challenges.
+func (p *Puzzle) Difficulty() DifficultyReport {
+	rep := DifficultyReport{Ops: make(map[syntax.Op]int)}
+	p.PatternOps(rep.Ops)
+	s, err := newSolver(p)
+	if err != nil {
+		rep.Err = err
+		return rep
+	}
+	rep.Cells = len(s.cellLines)
+
+	length := 0
+	for _, l := range s.lines {
+		runes := s.lineRunes(l)
+		for _, pat := range l.patterns {
+			rep.Patterns++
+			length += utf8.RuneCountInString(pat.expr)
+			count, _ := syntax.Count(pat.re, len(l.cells), runes)
+			n, _ := new(big.Float).SetInt(count).Float64()
+			if n > 1 {
+				rep.LineBits += math.Log2(n)
+			}
+		}
+	}
+	if rep.Patterns != 0 {
+		rep.PatternLength = float64(length) / float64(rep.Patterns)
+		rep.LineBits /= float64(rep.Patterns)
+	}
+
+	if s.propagate(append([]*line(nil), s.lines...)) {
+		fixed := 0
+		for i := range s.cellLines {
+			if size(s.dom(i)) == 1 {
+				fixed++
+			}
+		}
+		rep.Propagated = float64(fixed) / float64(rep.Cells)
+		s.search(func() bool {
+			rep.Solutions++
+			return rep.Solutions == 2
+		})
+		rep.Guesses = s.guesses
+	}
+
+	ops := 0.0
+	for op, n := range rep.Ops {
+		w, ok := opWeights[op]
+		if !ok {
+			w = 1
+		}
+		ops += w * float64(n)
+	}
+	if rep.Patterns != 0 {
+		ops /= float64(rep.Patterns)
+	}
+	rep.Score = ops +
+		rep.PatternLength/4 +
+		rep.LineBits/4 +
+		math.Log2(float64(rep.Cells)) +
+		5*(1-rep.Propagated) +
+		2*math.Log2(1+float64(rep.Guesses))
+	rep.Tier = DefaultTiers.Tier(rep.Score)
+	return rep
+}
+
+// lineRunes returns the runes that some cell of l may hold.
+func (s *solver) lineRunes(l *line) []rune {
+	d := make([]uint64, s.words)
+	for _, cell := range l.cells {
+		for i, w := range s.dom(cell) {
+			d[i] |= w
+		}
+	}
+	var runes []rune
+	for i, r := range s.runes {
+		if d[i/64]&(1<<uint(i%64)) != 0 {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// A Tier is a group of puzzles of similar difficulty, such as one of
+// the site's challenges.
+type Tier struct {
+	Name   string
+	Median float64 // median score of the tier's puzzles
+}
+
+// Tiers are ordered by increasing Median.
+type Tiers []Tier
+
+// DefaultTiers are the site's challenges of increasing difficulty,
+// with rough medians for Difficulty to suggest a tier without
+// calibration. CalibrateTiers fits tiers to the challenges instead.
+var DefaultTiers = Tiers{
+	{"Tutorial", 6},
+	{"Beginner", 10},
+	{"Intermediate", 14},
+	{"Experienced", 18},
+	{"Double Cross", 26},
+}
+
+// CalibrateTiers makes a tier of each challenge, named for it, from
+// the scores of its puzzles. Puzzles that cannot be solved, and
+// challenges without any others, are left out.
+func CalibrateTiers(challenges []Challenge) Tiers {
+	var tiers Tiers
+	for _, c := range challenges {
+		var scores []float64
+		for i := range c.Puzzles {
+			if d := c.Puzzles[i].Difficulty(); d.Err == nil {
+				scores = append(scores, d.Score)
+			}
+		}
+		if len(scores) == 0 {
+			continue
+		}
+		sort.Float64s(scores)
+		median := scores[len(scores)/2]
+		if len(scores)%2 == 0 {
+			median = (median + scores[len(scores)/2-1]) / 2
+		}
+		tiers = append(tiers, Tier{c.Name, median})
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return tiers[i].Median < tiers[j].Median
+	})
+	return tiers
+}
+
+// Tier returns the name of the tier whose median is nearest to score,
+// or "" if there are no tiers.
+func (t Tiers) Tier(score float64) string {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Median >= score })
+	if i == len(t) || i > 0 && score-t[i-1].Median < t[i].Median-score {
+		i--
+	}
+	if i < 0 {
+		return ""
+	}
+	return t[i].Name
+}
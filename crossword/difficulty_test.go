@@ -0,0 +1,184 @@
+package crossword
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+func TestDifficulty(t *testing.T) {
+	easy := Puzzle{
+		PatternsX: [][]string{{`AB|BA`, `.A.`, `B*A`}},
+		PatternsY: [][]string{{`A.`, `(B)A\1`, `[^A]+A`}},
+		PatternsZ: [][]string{{`(.)\1`, `A+`, `[AB]B`}},
+		Hexagonal: true,
+	}
+	e := easy.Difficulty()
+	if e.Err != nil {
+		t.Fatal(e.Err)
+	}
+	if e.Patterns != 9 || e.Cells != 7 || e.Solutions != 1 || e.Ops[syntax.OpBackref] != 2 {
+		t.Errorf("easy: got %+v", e)
+	}
+	if e.Tier == "" || e.Tier != DefaultTiers.Tier(e.Score) {
+		t.Errorf("easy: Tier = %q, want the nearest of DefaultTiers to %.1f", e.Tier, e.Score)
+	}
+
+	hard := mitPuzzle.Difficulty()
+	if hard.Err != nil {
+		t.Fatal(hard.Err)
+	}
+	if hard.Score <= e.Score || hard.LineBits <= e.LineBits || hard.PatternLength <= e.PatternLength {
+		t.Errorf("MIT is not harder than easy: got %+v, easy %+v", hard, e)
+	}
+
+	ambiguous := Puzzle{
+		PatternsX: [][]string{{`[AB]+`, `[AB]+`}},
+		PatternsY: [][]string{{`AB|BA`, `AB|BA`}},
+	}
+	a := ambiguous.Difficulty()
+	if a.Solutions != 2 || a.Guesses == 0 || a.Propagated != 0 {
+		t.Errorf("ambiguous: got %+v", a)
+	}
+
+	bad := Puzzle{PatternsX: [][]string{{`a(`}}, PatternsY: [][]string{{`a`}}}
+	if d := bad.Difficulty(); d.Err == nil {
+		t.Error("expected error for bad pattern")
+	}
+}
+
+func TestCalibrateTiers(t *testing.T) {
+	easy := Puzzle{
+		PatternsX: [][]string{{`A`, `B`}},
+		PatternsY: [][]string{{`AB`}},
+	}
+	medium := Puzzle{
+		PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+		PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+	}
+	bad := Puzzle{PatternsX: [][]string{{`a(`}}, PatternsY: [][]string{{`a`}}}
+	tiers := CalibrateTiers([]Challenge{
+		{Name: "Hard", Puzzles: []Puzzle{mitPuzzle}},
+		{Name: "Broken", Puzzles: []Puzzle{bad}},
+		{Name: "Easy", Puzzles: []Puzzle{easy, easy}},
+		{Name: "Medium", Puzzles: []Puzzle{medium, bad}},
+	})
+	var names []string
+	for _, tier := range tiers {
+		names = append(names, tier.Name)
+	}
+	if got, want := strings.Join(names, " "), "Easy Medium Hard"; got != want {
+		t.Fatalf("CalibrateTiers() = %s, want %s", got, want)
+	}
+	for _, test := range []struct {
+		Puzzle Puzzle
+		Tier   string
+	}{{easy, "Easy"}, {medium, "Medium"}, {mitPuzzle, "Hard"}} {
+		if got := tiers.Tier(test.Puzzle.Difficulty().Score); got != test.Tier {
+			t.Errorf("Tier(%v) = %q, want %q", test.Puzzle.PatternsX, got, test.Tier)
+		}
+	}
+	mid := (tiers[0].Median + tiers[1].Median) / 2
+	if got := tiers.Tier(mid - 0.01); got != "Easy" {
+		t.Errorf("Tier below midpoint = %q, want Easy", got)
+	}
+	if got := tiers.Tier(mid + 0.01); got != "Medium" {
+		t.Errorf("Tier above midpoint = %q, want Medium", got)
+	}
+	if got := tiers.Tier(-100); got != "Easy" {
+		t.Errorf("Tier(-100) = %q, want Easy", got)
+	}
+	if got := tiers.Tier(1000); got != "Hard" {
+		t.Errorf("Tier(1000) = %q, want Hard", got)
+	}
+	if got := Tiers(nil).Tier(1); got != "" {
+		t.Errorf("Tier with no tiers = %q", got)
+	}
+}
+
+// TestDifficultySnapshot checks the scores of the corpus against the
+// site's grouping into challenges and against the players' ratings.
+func TestDifficultySnapshot(t *testing.T) {
+	snap := testSnapshot(t)
+	tiers := CalibrateTiers(snap.Challenges)
+	if len(tiers) == 0 {
+		t.Fatal("no tiers")
+	}
+	agree, total := 0, 0
+	for _, c := range snap.Challenges {
+		for i := range c.Puzzles {
+			d := c.Puzzles[i].Difficulty()
+			if d.Err != nil {
+				continue
+			}
+			total++
+			if tiers.Tier(d.Score) == c.Name {
+				agree++
+			}
+		}
+	}
+	t.Logf("tiers %v place %d of %d challenge puzzles in their own challenge", tiers, agree, total)
+	if agree*len(tiers) <= total {
+		t.Errorf("tiers place %d of %d puzzles in their own challenge, no better than chance", agree, total)
+	}
+
+	var scores, ratings []float64
+	for i := range snap.Puzzles {
+		p := &snap.Puzzles[i]
+		if p.Votes == 0 {
+			continue
+		}
+		if d := p.Difficulty(); d.Err == nil {
+			scores = append(scores, d.Score)
+			ratings = append(ratings, p.RatingAvg)
+		}
+	}
+	rho := rankCorrelation(scores, ratings)
+	t.Logf("rank correlation of score and rating over %d puzzles: %.3f", len(scores), rho)
+	if len(scores) > 1 && rho <= 0 {
+		t.Errorf("scores do not rise with ratings: rank correlation %.3f", rho)
+	}
+}
+
+// rankCorrelation returns Spearman's rank correlation of x and y.
+func rankCorrelation(x, y []float64) float64 {
+	rx, ry := ranks(x), ranks(y)
+	n := float64(len(x))
+	mean := (n + 1) / 2
+	var cov, vx, vy float64
+	for i := range rx {
+		dx, dy := rx[i]-mean, ry[i]-mean
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// ranks returns the rank of each value of v, from 1, with ties given
+// their mean rank.
+func ranks(v []float64) []float64 {
+	order := make([]int, len(v))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return v[order[i]] < v[order[j]] })
+	r := make([]float64, len(v))
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && v[order[j]] == v[order[i]] {
+			j++
+		}
+		for k := i; k < j; k++ {
+			r[order[k]] = float64(i+j+1) / 2
+		}
+		i = j
+	}
+	return r
+}
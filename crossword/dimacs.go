@@ -0,0 +1,297 @@
+package crossword
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+	"github.com/andrewarchi/regexp-crossword/sparse"
+)
+
+// WriteDIMACS writes the puzzle as a SAT problem in DIMACS CNF format.
+//
+// Variable c*len(alphabet)+i+1 is true when cell c, counted row-major,
+// holds the i-th rune of the puzzle's alphabet, and each cell holds
+// exactly one rune of those that its Characters allow. The comment lines before the problem line map each
+// of these variables to its cell and rune, as
+//
+//	c v <var> <row> <col> <rune>
+//
+// with the rune quoted as a Go rune literal, and DecodeModel maps a
+// model back to a grid. The remaining variables are auxiliary.
+//
+// Each pattern is encoded as its automaton, unrolled over the length
+// of its line, so that a model chooses a path through the automaton
+// that spells the line. Patterns with backreferences or word
+// boundaries are instead encoded as a choice among the shapes of
+// their matches, when there are few enough, and otherwise the
+// automaton approximates them and a comment says so. Shapes only
+// approximate lookarounds, atomic groups and backreferences that
+// ignore case, so patterns with them get the comment either way.
+func (p *Puzzle) WriteDIMACS(w io.Writer) error {
+	s, err := newSolver(p)
+	if err != nil {
+		return err
+	}
+	e := &cnfEncoder{s: s, nvars: len(s.cellLines) * len(s.runes)}
+	e.cells()
+	for _, l := range s.lines {
+		for _, pat := range l.patterns {
+			if pat.tree && e.shapes(pat, l.cells) {
+				if approxShapes(pat.re) {
+					e.comments = append(e.comments, "approximate "+pat.expr)
+				}
+				continue
+			}
+			if pat.tree {
+				e.comments = append(e.comments, "approximate "+pat.expr)
+			}
+			e.automaton(pat, l.cells)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "c regexcrossword %s\n", p.ID)
+	for _, c := range e.comments {
+		fmt.Fprintf(bw, "c %s\n", c)
+	}
+	for cell, c := range s.layout.Cells {
+		for i, ch := range s.runes {
+			fmt.Fprintf(bw, "c v %d %d %d %s\n", e.cellVar(cell, i), c.Row, c.Col, strconv.QuoteRune(ch))
+		}
+	}
+	fmt.Fprintf(bw, "p cnf %d %d\n", e.nvars, len(e.clauses))
+	for _, clause := range e.clauses {
+		for _, lit := range clause {
+			bw.WriteString(strconv.Itoa(lit))
+			bw.WriteByte(' ')
+		}
+		bw.WriteString("0\n")
+	}
+	return bw.Flush()
+}
+
+// DecodeModel converts a model of the formula written by WriteDIMACS
+// to a grid. The model lists the variables set to true as positive
+// literals, as SAT solvers print them; other literals are ignored.
+func (p *Puzzle) DecodeModel(model []int) ([][]rune, error) {
+	s, err := newSolver(p)
+	if err != nil {
+		return nil, err
+	}
+	k := len(s.runes)
+	cells := make([]rune, len(s.cellLines))
+	for _, lit := range model {
+		if lit <= 0 || lit > len(cells)*k {
+			continue
+		}
+		c, i := (lit-1)/k, (lit-1)%k
+		if cells[c] != 0 {
+			return nil, fmt.Errorf("crossword: model assigns cell %d twice", c)
+		}
+		if !s.has(c, s.runes[i]) {
+			return nil, fmt.Errorf("crossword: model assigns cell %d %q, which it cannot hold", c, s.runes[i])
+		}
+		cells[c] = s.runes[i]
+	}
+	for _, ch := range cells {
+		if ch == 0 {
+			return nil, errors.New("crossword: model leaves a cell unassigned")
+		}
+	}
+	return s.layout.Grid(cells), nil
+}
+
+// cnfEncoder accumulates the clauses of a puzzle.
+type cnfEncoder struct {
+	s        *solver
+	nvars    int
+	clauses  [][]int
+	comments []string
+}
+
+func (e *cnfEncoder) newVar() int {
+	e.nvars++
+	return e.nvars
+}
+
+func (e *cnfEncoder) add(clause ...int) {
+	e.clauses = append(e.clauses, clause)
+}
+
+// addFalse adds clauses that cannot be satisfied, without relying on
+// solvers to accept the empty clause.
+func (e *cnfEncoder) addFalse() {
+	v := e.newVar()
+	e.add(v)
+	e.add(-v)
+}
+
+func (e *cnfEncoder) cellVar(cell, i int) int {
+	return cell*len(e.s.runes) + i + 1
+}
+
+// cells requires each cell to hold exactly one rune, and that rune to
+// be in the cell's domain.
+func (e *cnfEncoder) cells() {
+	k := len(e.s.runes)
+	for c := range e.s.cellLines {
+		e.add(e.inMask(c, e.s.dom(c))...)
+		for i := 0; i < k; i++ {
+			if !e.s.has(c, e.s.runes[i]) {
+				e.add(-e.cellVar(c, i))
+				continue
+			}
+			for j := i + 1; j < k; j++ {
+				if e.s.has(c, e.s.runes[j]) {
+					e.add(-e.cellVar(c, i), -e.cellVar(c, j))
+				}
+			}
+		}
+	}
+}
+
+// inMask returns the literals for the cell holding one of the runes in
+// mask.
+func (e *cnfEncoder) inMask(cell int, mask []uint64) []int {
+	var lits []int
+	for i := range e.s.runes {
+		if mask[i/64]&(1<<uint(i%64)) != 0 {
+			lits = append(lits, e.cellVar(cell, i))
+		}
+	}
+	return lits
+}
+
+// automaton encodes the pattern's program unrolled over the cells.
+// A state variable for each rune instruction pc at each position i
+// means that the path consumes cell i with pc. A chosen state implies
+// that its cell matches the instruction and that some successor state
+// is chosen, and some start state must be chosen. Only the states that
+// lie on some accepting path are encoded.
+func (e *cnfEncoder) automaton(pat *pattern, cells []int) {
+	if _, ok := pat.support(e.s, cells); !ok {
+		e.addFalse()
+		return
+	}
+	n := len(cells)
+	prog := pat.prog
+	states := make([]map[uint32]int, n)
+	for i := range states {
+		states[i] = make(map[uint32]int)
+		for _, pc := range pat.fwd[i].Values() {
+			if pat.runes[pc] != nil && pat.live[i].Has(pc) {
+				states[i][pc] = e.newVar()
+			}
+		}
+	}
+	q := sparse.NewSet(uint32(len(prog.Inst)))
+	successors := func(pc uint32, pos int) []int {
+		q.Reset()
+		pat.addThread(q, pc, pos, n)
+		var lits []int
+		for _, pc := range q.Values() {
+			if v, ok := states[pos][pc]; ok {
+				lits = append(lits, v)
+			}
+		}
+		return lits
+	}
+
+	e.add(successors(uint32(prog.Start), 0)...)
+	for i, cell := range cells {
+		for _, pc := range pat.fwd[i].Values() {
+			v, ok := states[i][pc]
+			if !ok {
+				continue
+			}
+			e.add(append([]int{-v}, e.inMask(cell, pat.runes[pc])...)...)
+			if i+1 < n {
+				e.add(append([]int{-v}, successors(prog.Inst[pc].Out, i+1)...)...)
+			}
+		}
+	}
+}
+
+// shapes encodes the pattern as a choice among the shapes of its
+// matches, as enumerated over a domainLine: a selector variable for
+// each shape implies that each cell holds a rune of the shape's
+// domain for it and that cells made equal by backreferences hold the
+// same rune. It reports false if there are too many shapes.
+func (e *cnfEncoder) shapes(pat *pattern, cells []int) bool {
+	l := newDomainLine(e.s, pat, cells)
+	type shape struct {
+		roots []int
+		doms  [][]uint64
+	}
+	var shapes []shape
+	m := syntax.NewMatcher(pat.re, l, matchBudget)
+	m.Match(func(i int) bool {
+		if i != l.n {
+			return false
+		}
+		sh := shape{make([]int, l.n), make([][]uint64, l.n)}
+		for pos := range sh.roots {
+			sh.roots[pos] = l.find(pos)
+			sh.doms[pos] = l.dom[sh.roots[pos]]
+		}
+		shapes = append(shapes, sh)
+		return false
+	})
+	if m.Exhausted() {
+		return false
+	}
+	if len(shapes) == 0 {
+		e.addFalse()
+		return true
+	}
+
+	selectors := make([]int, len(shapes))
+	for k, sh := range shapes {
+		t := e.newVar()
+		selectors[k] = t
+		for pos, root := range sh.roots {
+			if root != pos {
+				for i := range e.s.runes {
+					e.add(-t, -e.cellVar(cells[pos], i), e.cellVar(cells[root], i))
+				}
+				continue
+			}
+			if !equalDom(sh.doms[pos], e.s.dom(cells[pos])) {
+				e.add(append([]int{-t}, e.inMask(cells[pos], sh.doms[pos])...)...)
+			}
+		}
+	}
+	e.add(selectors...)
+	return true
+}
+
+// approxShapes reports whether re has ops that shapes approximate.
+func approxShapes(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLookahead, syntax.OpNegLookahead, syntax.OpLookbehind, syntax.OpNegLookbehind,
+		syntax.OpAtomic:
+		return true
+	case syntax.OpBackref:
+		// Runes equal up to case are not tied together.
+		return re.Flags&syntax.FoldCase != 0
+	}
+	for _, sub := range re.Sub {
+		if approxShapes(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalDom(a, b []uint64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package crossword
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteDIMACS(t *testing.T) {
+	for i, p := range []Puzzle{
+		{
+			PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+			PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+		},
+		{
+			PatternsX: [][]string{{`A+B+A`, `(B|C)\1*`, `[AB]*`}, {`.*`, `.C.`, `(A|B)B\1`}},
+			PatternsY: [][]string{{`(.).\1`, `[^A]C.`, `A.*`}},
+		},
+		{
+			PatternsX: [][]string{{`AB|BA`, `.A.`, `B*A`}},
+			PatternsY: [][]string{{`A.`, `(B)A\1`, `[^A]+A`}},
+			PatternsZ: [][]string{{`(.)\1`, `A+`, `[AB]B`}},
+			Hexagonal: true,
+		},
+		{
+			PatternsX:      [][]string{{`A|C`, `.`}},
+			PatternsY:      [][]string{{`.B`}},
+			Characters:     []string{"AB", "BC"},
+			CellCharacters: true,
+		},
+		{
+			PatternsX:      [][]string{{`.`, `.`}},
+			PatternsY:      [][]string{{`(.)\1|CA`}},
+			Characters:     []string{"AB", "BC"},
+			CellCharacters: true,
+		},
+	} {
+		want, err := p.Solve()
+		if err != nil {
+			t.Fatalf("puzzle %d: %v", i, err)
+		}
+		f := writeCNF(t, &p)
+		model, ok := f.solve(nil)
+		if !ok {
+			t.Errorf("puzzle %d: formula is unsatisfiable", i)
+			continue
+		}
+		got, err := p.DecodeModel(model)
+		if err != nil {
+			t.Errorf("puzzle %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("puzzle %d: got %q, want %q", i, gridStrings(got), gridStrings(want))
+		}
+		// The solution is unique, so excluding it leaves no model.
+		f.clauses = append(f.clauses, f.block(want))
+		if _, ok := f.solve(nil); ok {
+			t.Errorf("puzzle %d: formula has a second model", i)
+		}
+	}
+}
+
+func TestWriteDIMACSNoSolution(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`A`, `B`}},
+		PatternsY: [][]string{{`AA|BB`}},
+	}
+	if _, ok := writeCNF(t, &p).solve(nil); ok {
+		t.Error("formula is satisfiable")
+	}
+}
+
+func TestDecodeModelDomain(t *testing.T) {
+	p := Puzzle{
+		PatternsX:      [][]string{{`.`, `.`}},
+		PatternsY:      [][]string{{`..`}},
+		Characters:     []string{"AB", "BC"},
+		CellCharacters: true,
+	}
+	f := writeCNF(t, &p)
+	grid := [][]rune{[]rune("CB")}
+	if _, ok := f.solve(f.assign(grid)); ok {
+		t.Error("grid with a rune outside its cell's characters satisfies the formula")
+	}
+	if _, err := p.DecodeModel([]int{f.cells["0 0 C"], f.cells["0 1 B"]}); err == nil {
+		t.Error("DecodeModel accepted a rune outside its cell's characters")
+	}
+}
+
+func TestWriteDIMACSMIT(t *testing.T) {
+	f := writeCNF(t, &mitPuzzle)
+	var grid [][]rune
+	for _, row := range mitSolution {
+		grid = append(grid, []rune(row))
+	}
+	if _, ok := f.solve(f.assign(grid)); !ok {
+		t.Error("solution does not satisfy the formula")
+	}
+	grid[0][0] = 'M'
+	if _, ok := f.solve(f.assign(grid)); ok {
+		t.Error("altered solution satisfies the formula")
+	}
+}
+
+// cnf is a formula read from DIMACS, with the cell variable map.
+type cnf struct {
+	nvars   int
+	clauses [][]int
+	vars    map[int]string // "row col rune"
+	cells   map[string]int
+}
+
+func writeCNF(t *testing.T, p *Puzzle) *cnf {
+	t.Helper()
+	var b bytes.Buffer
+	if err := p.WriteDIMACS(&b); err != nil {
+		t.Fatal(err)
+	}
+	f := &cnf{vars: make(map[int]string), cells: make(map[string]int)}
+	sc := bufio.NewScanner(&b)
+	var clause []int
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		switch {
+		case len(fields) == 6 && fields[0] == "c" && fields[1] == "v":
+			v, _ := strconv.Atoi(fields[2])
+			ch, err := strconv.Unquote(fields[5])
+			if err != nil {
+				t.Fatalf("bad rune in %q", sc.Text())
+			}
+			key := fields[3] + " " + fields[4] + " " + ch
+			f.vars[v] = key
+			f.cells[key] = v
+		case len(fields) != 0 && fields[0] == "c":
+		case len(fields) == 4 && fields[0] == "p":
+			f.nvars, _ = strconv.Atoi(fields[2])
+		default:
+			for _, field := range fields {
+				lit, err := strconv.Atoi(field)
+				if err != nil {
+					t.Fatalf("bad literal in %q", sc.Text())
+				}
+				if lit == 0 {
+					f.clauses = append(f.clauses, clause)
+					clause = nil
+				} else {
+					clause = append(clause, lit)
+				}
+			}
+		}
+	}
+	return f
+}
+
+// block returns a clause that excludes the grid.
+func (f *cnf) block(grid [][]rune) []int {
+	var clause []int
+	for r, row := range grid {
+		for c, ch := range row {
+			clause = append(clause, -f.cells[fmt.Sprintf("%d %d %c", r, c, ch)])
+		}
+	}
+	return clause
+}
+
+// assign returns the literals that fix every cell to the grid.
+func (f *cnf) assign(grid [][]rune) []int {
+	var lits []int
+	for v, key := range f.vars {
+		var r, c int
+		var ch rune
+		fmt.Sscanf(key, "%d %d %c", &r, &c, &ch)
+		if grid[r][c] == ch {
+			lits = append(lits, v)
+		} else {
+			lits = append(lits, -v)
+		}
+	}
+	return lits
+}
+
+// solve finds a model by DPLL and returns its true variables.
+func (f *cnf) solve(assume []int) ([]int, bool) {
+	val := make([]int8, f.nvars+1)
+	for _, lit := range assume {
+		if lit > 0 {
+			val[lit] = 1
+		} else {
+			val[-lit] = -1
+		}
+	}
+	if !f.dpll(val) {
+		return nil, false
+	}
+	var model []int
+	for v := 1; v <= f.nvars; v++ {
+		if val[v] > 0 {
+			model = append(model, v)
+		}
+	}
+	return model, true
+}
+
+func (f *cnf) dpll(val []int8) bool {
+	litVal := func(lit int) int8 {
+		if lit > 0 {
+			return val[lit]
+		}
+		return -val[-lit]
+	}
+	var trail []int
+	undo := func() {
+		for _, v := range trail {
+			val[v] = 0
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, clause := range f.clauses {
+			unset, free := 0, 0
+			sat := false
+			for _, lit := range clause {
+				switch litVal(lit) {
+				case 1:
+					sat = true
+				case 0:
+					unset++
+					free = lit
+				}
+			}
+			if sat {
+				continue
+			}
+			if unset == 0 {
+				undo()
+				return false
+			}
+			if unset == 1 {
+				if free > 0 {
+					val[free] = 1
+					trail = append(trail, free)
+				} else {
+					val[-free] = -1
+					trail = append(trail, -free)
+				}
+				changed = true
+			}
+		}
+	}
+	for v := 1; v < len(val); v++ {
+		if val[v] == 0 {
+			for _, b := range []int8{1, -1} {
+				val[v] = b
+				if f.dpll(val) {
+					return true
+				}
+			}
+			val[v] = 0
+			undo()
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,351 @@
+package crossword
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/andrewarchi/regexp-crossword/regexp"
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+// ErrNotUnique is returned by Generate when it cannot make the grid
+// the unique solution within its rounds.
+var ErrNotUnique = errors.New("crossword: could not make the solution unique")
+
+// DefaultGenOps are the kinds of patterns that Generate builds when
+// GenOptions.Ops is empty.
+var DefaultGenOps = []syntax.Op{
+	syntax.OpLiteral,
+	syntax.OpCharClass,
+	syntax.OpAlternate,
+	syntax.OpStar,
+	syntax.OpBackref,
+}
+
+// GenOptions configure Generate.
+type GenOptions struct {
+	// Ops are the kinds of patterns to build. Each pattern is a
+	// sequence of pieces that each match a few runes of the line:
+	//
+	//	OpLiteral       the runes themselves
+	//	OpCharClass     a class of each rune and decoys, maybe negated
+	//	OpAnyCharNotNL  a dot for each rune
+	//	OpAlternate     the runes or a decoy string, as a group
+	//	OpStar          a class of the runes and a decoy, repeated
+	//	OpPlus          likewise, at least once
+	//	OpQuest         the runes followed by an optional decoy
+	//	OpRepeat        a class of the runes and a decoy, counted
+	//	OpBackref       a reference to an earlier group with the same runes
+	//
+	// Literals are always allowed, since Generate falls back to them
+	// to make the solution unique.
+	Ops []syntax.Op
+
+	// Alphabet holds the runes that decoys are drawn from, which
+	// become the puzzle's Characters. It must include every rune of
+	// the grid, and is those runes if empty.
+	Alphabet string
+
+	Hexagonal bool  // the grid is a hexagon, as laid out by Layout
+	Seed      int64 // seeds the choices, so that output is reproducible
+	MaxRounds int   // rounds of tightening; 50 if 0
+}
+
+// Generate builds a puzzle with one pattern per line whose unique
+// solution is grid.
+//
+// Each round generates patterns that the lines of grid match and
+// solves the puzzle. When there is another solution, the patterns of
+// the lines where it differs from grid are regenerated with more of
+// their runes given literally, until grid is the only solution.
+func Generate(grid [][]rune, opts GenOptions) (*Puzzle, error) {
+	ops := opts.Ops
+	if len(ops) == 0 {
+		ops = DefaultGenOps
+	}
+	g := &generator{rng: rand.New(rand.NewSource(opts.Seed))}
+	for _, op := range ops {
+		switch op {
+		case syntax.OpLiteral:
+		case syntax.OpBackref:
+			g.backrefs = true
+		case syntax.OpCharClass, syntax.OpAnyCharNotNL, syntax.OpAlternate,
+			syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+			g.ops = append(g.ops, op)
+		default:
+			return nil, fmt.Errorf("crossword: cannot generate patterns with %v", op)
+		}
+	}
+
+	var cells []rune
+	for _, row := range grid {
+		cells = append(cells, row...)
+	}
+	alphabet := opts.Alphabet
+	if alphabet == "" {
+		alphabet = string(cells)
+	}
+	set := make(map[rune]bool)
+	for _, r := range alphabet {
+		if !set[r] {
+			set[r] = true
+			g.alphabet = append(g.alphabet, r)
+		}
+	}
+	for _, r := range cells {
+		if !set[r] {
+			return nil, fmt.Errorf("crossword: %q of grid is not in the alphabet", r)
+		}
+	}
+
+	p, l, err := skeleton(grid, opts.Hexagonal)
+	if err != nil {
+		return nil, err
+	}
+	p.Characters = []string{string(g.alphabet)}
+
+	strength := make([]float64, len(l.Lines))
+	regen := make([]bool, len(l.Lines))
+	for i := range regen {
+		regen[i] = true
+	}
+	rounds := opts.MaxRounds
+	if rounds == 0 {
+		rounds = 50
+	}
+	for round := 0; round < rounds; round++ {
+		for i, line := range l.Lines {
+			if !regen[i] {
+				continue
+			}
+			text := make([]rune, len(line.Cells))
+			for j, cell := range line.Cells {
+				text[j] = cells[cell]
+			}
+			expr := g.pattern(text, strength[i])
+			switch line.Axis {
+			case AxisX:
+				p.PatternsX[0][line.Index] = expr
+			case AxisY:
+				p.PatternsY[0][line.Index] = expr
+			case AxisZ:
+				p.PatternsZ[0][line.Index] = expr
+			}
+			regen[i] = false
+		}
+
+		grids, err := p.Solutions(2)
+		if err != nil {
+			return nil, err
+		}
+		var other []rune
+		for _, sol := range grids {
+			var c []rune
+			for _, row := range sol {
+				c = append(c, row...)
+			}
+			if string(c) != string(cells) {
+				other = c
+				break
+			}
+		}
+		if other == nil {
+			if len(grids) == 0 {
+				return nil, errors.New("crossword: generated patterns do not match the grid")
+			}
+			return p, nil
+		}
+		for i, line := range l.Lines {
+			for _, cell := range line.Cells {
+				if other[cell] != cells[cell] {
+					regen[i] = true
+					strength[i] += 0.25
+					break
+				}
+			}
+		}
+	}
+	return nil, ErrNotUnique
+}
+
+// skeleton returns a puzzle with blank patterns in the shape of grid
+// and its layout.
+func skeleton(grid [][]rune, hexagonal bool) (*Puzzle, *Layout, error) {
+	if len(grid) == 0 || len(grid[0]) == 0 {
+		return nil, nil, errors.New("crossword: empty grid")
+	}
+	p := &Puzzle{Hexagonal: hexagonal}
+	width := len(grid[0])
+	if hexagonal {
+		p.Size = (len(grid) + 1) / 2
+		width = len(grid)
+		p.PatternsZ = [][]string{make([]string, width)}
+	}
+	p.PatternsX = [][]string{make([]string, width)}
+	p.PatternsY = [][]string{make([]string, len(grid))}
+	l, err := p.Layout()
+	if err != nil {
+		return nil, nil, err
+	}
+	for r, row := range grid {
+		if len(row) != l.Rows[r] {
+			return nil, nil, fmt.Errorf("crossword: row %d of grid has %d cells, want %d", r, len(row), l.Rows[r])
+		}
+	}
+	return p, l, nil
+}
+
+// generator builds patterns from random pieces.
+type generator struct {
+	rng      *rand.Rand
+	alphabet []rune
+	ops      []syntax.Op
+	backrefs bool
+}
+
+// pattern returns a pattern that matches text. Strength is the chance
+// that each piece is given literally.
+func (g *generator) pattern(text []rune, strength float64) string {
+	var b strings.Builder
+	var groups []string // text captured by each group
+	for i := 0; i < len(text); {
+		n := 1 + g.rng.Intn(3)
+		if i+n > len(text) {
+			n = len(text) - i
+		}
+		chunk := text[i : i+n]
+		i += n
+
+		if g.backrefs && g.rng.Float64() >= strength {
+			if k := indexString(groups, string(chunk)); k >= 0 && k < 9 && g.rng.Intn(4) != 0 {
+				b.WriteString(`\` + strconv.Itoa(k+1))
+				continue
+			}
+		}
+		op := syntax.OpLiteral
+		if len(g.ops) != 0 && g.rng.Float64() >= strength {
+			op = g.ops[g.rng.Intn(len(g.ops))]
+		}
+		piece := g.piece(op, chunk)
+		if op == syntax.OpAlternate {
+			groups = append(groups, string(chunk))
+		} else if g.backrefs && len(groups) < 9 && g.rng.Intn(3) == 0 {
+			piece = "(" + piece + ")"
+			groups = append(groups, string(chunk))
+		}
+		b.WriteString(piece)
+	}
+	expr := b.String()
+	// The pieces match by construction, but check anyway, so that a
+	// bad piece costs only a literal line.
+	re, err := syntax.Parse(expr, parseFlags)
+	if err != nil || !syntax.MatchRunes(re.Simplify(), text) {
+		return regexp.QuoteMeta(string(text))
+	}
+	return expr
+}
+
+// piece returns a pattern of kind op that matches chunk.
+func (g *generator) piece(op syntax.Op, chunk []rune) string {
+	switch op {
+	case syntax.OpCharClass:
+		var b strings.Builder
+		for _, r := range chunk {
+			decoys := g.decoys(r, 1+g.rng.Intn(2))
+			if len(decoys) != 0 && g.rng.Intn(2) == 0 {
+				b.WriteString("[^" + classString(decoys) + "]")
+			} else {
+				b.WriteString("[" + classString(g.shuffle(append(decoys, r))) + "]")
+			}
+		}
+		return b.String()
+	case syntax.OpAnyCharNotNL:
+		return strings.Repeat(".", len(chunk))
+	case syntax.OpAlternate:
+		decoy := make([]rune, len(chunk))
+		for i := range decoy {
+			decoy[i] = g.alphabet[g.rng.Intn(len(g.alphabet))]
+		}
+		alts := []string{regexp.QuoteMeta(string(chunk)), regexp.QuoteMeta(string(decoy))}
+		if alts[0] == alts[1] {
+			alts = alts[:1]
+		}
+		g.rng.Shuffle(len(alts), func(i, j int) { alts[i], alts[j] = alts[j], alts[i] })
+		return "(" + strings.Join(alts, "|") + ")"
+	case syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		class := g.classOf(chunk)
+		switch op {
+		case syntax.OpStar:
+			return class + "*"
+		case syntax.OpPlus:
+			return class + "+"
+		}
+		return class + "{" + strconv.Itoa(len(chunk)) + "}"
+	case syntax.OpQuest:
+		decoy := g.alphabet[g.rng.Intn(len(g.alphabet))]
+		return regexp.QuoteMeta(string(chunk)) + regexp.QuoteMeta(string(decoy)) + "?"
+	}
+	return regexp.QuoteMeta(string(chunk))
+}
+
+// classOf returns a class of the runes of chunk and maybe a decoy.
+func (g *generator) classOf(chunk []rune) string {
+	var runes []rune
+	for _, r := range chunk {
+		if !containsRune(runes, r) {
+			runes = append(runes, r)
+		}
+	}
+	if g.rng.Intn(2) == 0 {
+		runes = append(runes, g.decoys(runes[0], 1)...)
+	}
+	runes = g.shuffle(runes)
+	if len(runes) == 1 {
+		return regexp.QuoteMeta(string(runes))
+	}
+	return "[" + classString(runes) + "]"
+}
+
+// decoys returns up to n distinct runes of the alphabet other than r.
+func (g *generator) decoys(r rune, n int) []rune {
+	var decoys []rune
+	for _, i := range g.rng.Perm(len(g.alphabet)) {
+		if len(decoys) == n {
+			break
+		}
+		if d := g.alphabet[i]; d != r {
+			decoys = append(decoys, d)
+		}
+	}
+	return decoys
+}
+
+func (g *generator) shuffle(runes []rune) []rune {
+	g.rng.Shuffle(len(runes), func(i, j int) { runes[i], runes[j] = runes[j], runes[i] })
+	return runes
+}
+
+// classString writes runes for use inside a character class.
+func classString(runes []rune) string {
+	var b strings.Builder
+	for _, r := range runes {
+		switch r {
+		case '\\', ']', '[', '^', '-':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func indexString(list []string, s string) int {
+	for i, t := range list {
+		if t == s {
+			return i
+		}
+	}
+	return -1
+}
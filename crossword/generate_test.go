@@ -0,0 +1,61 @@
+package crossword
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+func TestGenerate(t *testing.T) {
+	for i, test := range []struct {
+		Grid []string
+		Opts GenOptions
+	}{
+		{[]string{"HELP", "ABBA", "LOOP"}, GenOptions{Seed: 1}},
+		{[]string{"HELP", "ABBA", "LOOP"}, GenOptions{Seed: 2, Alphabet: "ABEHLOPXYZ"}},
+		{[]string{"AB", "BAB", "BA"}, GenOptions{Seed: 3, Hexagonal: true}},
+		{mitSolution, GenOptions{Seed: 4, Hexagonal: true}},
+		{mitSolution, GenOptions{Seed: 5, Hexagonal: true,
+			Ops: []syntax.Op{syntax.OpAnyCharNotNL, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat}}},
+	} {
+		grid := runeGrid(test.Grid...)
+		p, err := Generate(grid, test.Opts)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		for _, axis := range [][][]string{p.PatternsX, p.PatternsY, p.PatternsZ} {
+			if len(axis) > 1 {
+				t.Errorf("test %d: got %d sides, want 1", i, len(axis))
+			}
+		}
+		grids, err := p.Solutions(2)
+		if err != nil || len(grids) != 1 || !reflect.DeepEqual(gridStrings(grids[0]), test.Grid) {
+			t.Errorf("test %d: got solutions %q, %v, want only %q", i, grids, err, test.Grid)
+		}
+		if res := p.Check(grid); !res.Solved {
+			t.Errorf("test %d: grid does not check: %+v", i, res.Failed())
+		}
+		q, err := Generate(grid, test.Opts)
+		if err != nil || !reflect.DeepEqual(p, q) {
+			t.Errorf("test %d: generating again with the same seed gave a different puzzle", i)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	grid := runeGrid("AB", "BA")
+	for _, opts := range []GenOptions{
+		{Ops: []syntax.Op{syntax.OpWordBoundary}},
+		{Alphabet: "AC"},
+		{Hexagonal: true},
+	} {
+		if _, err := Generate(grid, opts); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+	if _, err := Generate(runeGrid("AB", "A"), GenOptions{}); err == nil {
+		t.Error("expected error for ragged grid")
+	}
+}
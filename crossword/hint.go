@@ -0,0 +1,178 @@
+package crossword
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoHint is returned by Hint when no empty cell can be deduced by
+// reasoning about the patterns of its lines alone.
+var ErrNoHint = errors.New("crossword: no cell can be deduced")
+
+// Hint is a cell whose rune is forced by the filled cells of a partial
+// grid and the patterns that force it.
+type Hint struct {
+	Row, Col int
+	Rune     rune
+	Patterns []string
+}
+
+// Hint finds an empty cell of the partial grid whose rune is forced,
+// preferring the simplest deduction. The partial grid has the shape of
+// the puzzle, with 0 in each empty cell.
+//
+// A cell forced by a single pattern, given only the filled cells of
+// its line, is the simplest. Otherwise, the patterns are propagated
+// together, as Solve does, and the forced cell that depends on the
+// fewest patterns is chosen. Hint returns ErrNoHint if propagation
+// forces no empty cell, which happens when the puzzle must be solved
+// by trial, and ErrNoSolution if the filled cells contradict the
+// patterns.
+func (p *Puzzle) Hint(partial [][]rune) (Hint, error) {
+	s, err := newSolver(p)
+	if err != nil {
+		return Hint{}, err
+	}
+	empty, err := s.fill(partial)
+	if err != nil {
+		return Hint{}, err
+	}
+	given := append([]uint64(nil), s.doms...)
+	if !s.propagate(append([]*line(nil), s.lines...)) {
+		return Hint{}, ErrNoSolution
+	}
+	copy(s.doms, given)
+
+	// A single pattern, on the filled cells alone.
+	for _, l := range s.lines {
+		for _, pat := range l.patterns {
+			sup, ok := pat.supports(s, l.cells)
+			if !ok {
+				return Hint{}, ErrNoSolution
+			}
+			for i, cell := range l.cells {
+				d := sup[i*s.words : (i+1)*s.words]
+				if empty[cell] && size(d) == 1 {
+					return s.hint(cell, s.first(d), []*pattern{pat}), nil
+				}
+			}
+		}
+	}
+
+	// Patterns together, tracking the patterns that each cell's
+	// domain depends on.
+	reasons := make([][]*pattern, len(s.cellLines))
+	queue := append([]*line(nil), s.lines...)
+	for _, l := range queue {
+		l.queued = true
+	}
+	defer func() {
+		for _, l := range queue {
+			l.queued = false
+		}
+	}()
+	for len(queue) != 0 {
+		l := queue[0]
+		queue = queue[1:]
+		l.queued = false
+		for _, pat := range l.patterns {
+			sup, ok := pat.supports(s, l.cells)
+			if !ok {
+				return Hint{}, ErrNoSolution
+			}
+			var because []*pattern
+			for i, cell := range l.cells {
+				d := s.dom(cell)
+				if equalDom(d, sup[i*s.words:(i+1)*s.words]) {
+					continue
+				}
+				copy(d, sup[i*s.words:(i+1)*s.words])
+				if because == nil {
+					because = []*pattern{pat}
+					for _, c := range l.cells {
+						because = unionPatterns(because, reasons[c])
+					}
+				}
+				reasons[cell] = unionPatterns(reasons[cell], because)
+				for _, l2 := range s.cellLines[cell] {
+					if !l2.queued {
+						l2.queued = true
+						queue = append(queue, l2)
+					}
+				}
+			}
+		}
+	}
+
+	best := -1
+	for cell, d := range reasons {
+		if empty[cell] && size(s.dom(cell)) == 1 && d != nil &&
+			(best < 0 || len(d) < len(reasons[best])) {
+			best = cell
+		}
+	}
+	if best < 0 {
+		return Hint{}, ErrNoHint
+	}
+	return s.hint(best, s.first(s.dom(best)), reasons[best]), nil
+}
+
+// fill fixes the domains of the filled cells of partial and reports
+// which cells are empty.
+func (s *solver) fill(partial [][]rune) ([]bool, error) {
+	rows := s.layout.Rows
+	if len(partial) != len(rows) {
+		return nil, fmt.Errorf("crossword: partial grid has %d rows, want %d", len(partial), len(rows))
+	}
+	empty := make([]bool, len(s.cellLines))
+	cell := 0
+	for r, row := range partial {
+		if len(row) != rows[r] {
+			return nil, fmt.Errorf("crossword: row %d of partial grid has %d cells, want %d", r, len(row), rows[r])
+		}
+		for c, ch := range row {
+			if ch == 0 {
+				empty[cell] = true
+			} else {
+				i := s.index(ch)
+				if i < 0 {
+					return nil, fmt.Errorf("crossword: %q at row %d, column %d is not in the alphabet", ch, r, c)
+				}
+				d := s.dom(cell)
+				for j := range d {
+					d[j] = 0
+				}
+				d[i/64] = 1 << uint(i%64)
+			}
+			cell++
+		}
+	}
+	return empty, nil
+}
+
+func (s *solver) hint(cell int, r rune, pats []*pattern) Hint {
+	c := s.layout.Cells[cell]
+	h := Hint{Row: c.Row, Col: c.Col, Rune: r}
+	for _, pat := range pats {
+		h.Patterns = append(h.Patterns, pat.expr)
+	}
+	return h
+}
+
+// unionPatterns returns the union of the sets a and b, each ordered
+// by the index of the patterns.
+func unionPatterns(a, b []*pattern) []*pattern {
+	u := make([]*pattern, 0, len(a)+len(b))
+	for len(a) != 0 && len(b) != 0 {
+		switch {
+		case a[0].index < b[0].index:
+			u, a = append(u, a[0]), a[1:]
+		case a[0].index > b[0].index:
+			u, b = append(u, b[0]), b[1:]
+		default:
+			u, a, b = append(u, a[0]), a[1:], b[1:]
+		}
+	}
+	u = append(u, a...)
+	return append(u, b...)
+}
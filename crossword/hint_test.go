@@ -0,0 +1,90 @@
+package crossword
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHint(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`[AB]`, `[BC]`}},
+		PatternsY: [][]string{{`A.`}},
+	}
+	h, err := p.Hint([][]rune{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hint{Row: 0, Col: 0, Rune: 'A', Patterns: []string{`A.`}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got %+v, want %+v", h, want)
+	}
+
+	// Neither pattern forces a cell alone.
+	p = Puzzle{
+		PatternsX: [][]string{{`A|B`, `B|C`}},
+		PatternsY: [][]string{{`AA|BB|CC`}},
+	}
+	h, err = p.Hint([][]rune{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Hint{Row: 0, Col: 0, Rune: 'B', Patterns: []string{`AA|BB|CC`, `A|B`, `B|C`}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got %+v, want %+v", h, want)
+	}
+	h, err = p.Hint([][]rune{{'B', 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Hint{Row: 0, Col: 1, Rune: 'B', Patterns: []string{`AA|BB|CC`}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got %+v, want %+v", h, want)
+	}
+}
+
+func TestHintErrors(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`A|B`, `B`}},
+		PatternsY: [][]string{{`AB|BA`}},
+	}
+	if _, err := p.Hint([][]rune{{'B', 0}}); err != ErrNoSolution {
+		t.Errorf("contradiction: got error %v, want %v", err, ErrNoSolution)
+	}
+	if _, err := p.Hint([][]rune{{'A', 'B'}}); err != ErrNoHint {
+		t.Errorf("complete grid: got error %v, want %v", err, ErrNoHint)
+	}
+	if _, err := p.Hint([][]rune{{0}}); err == nil {
+		t.Error("wrong shape: got no error")
+	}
+	if _, err := p.Hint([][]rune{{'Z', 0}}); err == nil {
+		t.Error("rune outside alphabet: got no error")
+	}
+}
+
+func TestHintMIT(t *testing.T) {
+	var partial [][]rune
+	for _, row := range mitSolution {
+		partial = append(partial, make([]rune, len(row)))
+	}
+	filled := 0
+	for {
+		h, err := mitPuzzle.Hint(partial)
+		if err == ErrNoHint {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []rune(mitSolution[h.Row])[h.Col]; h.Rune != want {
+			t.Fatalf("hint %+v, want %q", h, want)
+		}
+		if len(h.Patterns) == 0 {
+			t.Fatalf("hint %+v has no patterns", h)
+		}
+		partial[h.Row][h.Col] = h.Rune
+		filled++
+	}
+	if filled < 100 {
+		t.Errorf("filled %d cells by hints, want most of them", filled)
+	}
+}
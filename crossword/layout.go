@@ -0,0 +1,269 @@
+package crossword
+
+import "fmt"
+
+// Layout is the geometry of a puzzle: its cells and the lines of cells
+// that its patterns clue.
+//
+// Cells are numbered in row-major order, which is also the order of
+// the runes in a grid such as Solve returns, so that cell i is
+// grid[Cells[i].Row][Cells[i].Col].
+//
+// The Characters of a puzzle restrict the runes of its cells. If
+// CellCharacters is set, there must be one string for each cell, and
+// string i lists the runes allowed in cell i; otherwise, the strings
+// together list the runes allowed in every cell, however many there
+// are. If Characters is empty, any rune named by the patterns is
+// allowed.
+//
+// The SolutionMap of a puzzle, if set, lists cells by index in the
+// order that the site reads its solution.
+type Layout struct {
+	Hexagonal bool
+	Size      int    // side of a hexagonal grid
+	Rows      []int  // number of cells in each row
+	Cells     []Cell // in row-major order
+	Lines     []Line // rows, then the X lines, then the Z lines
+
+	// SolutionMap is the order in which to read the cells as a
+	// solution string.
+	SolutionMap []int
+}
+
+// Cell is a cell of a layout.
+type Cell struct {
+	Row, Col int // position in the grid
+
+	// X, Y and Z are the indices of the lines through the cell on
+	// each axis. Y is the row; X is the column of a rectangular grid;
+	// Z is -1 unless the grid is hexagonal.
+	X, Y, Z int
+
+	// Runes are the runes allowed by Characters in this cell alone,
+	// or nil if Characters does not restrict the cell by itself.
+	Runes []rune
+}
+
+// Axis is a direction of lines in a grid.
+type Axis uint8
+
+// The axes of a grid. The patterns of each axis are in the
+// corresponding field of Puzzle.
+const (
+	AxisX Axis = iota // columns of a rectangular grid
+	AxisY             // rows
+	AxisZ             // third axis of a hexagonal grid
+)
+
+func (a Axis) String() string {
+	switch a {
+	case AxisX:
+		return "x"
+	case AxisY:
+		return "y"
+	case AxisZ:
+		return "z"
+	}
+	return fmt.Sprintf("Axis(%d)", uint8(a))
+}
+
+// Line is a line of cells clued by a pattern from each side of the
+// grid.
+type Line struct {
+	Axis     Axis
+	Index    int      // index of the line on its axis
+	Cells    []int    // in reading order
+	Patterns []string // one per side; blank patterns place no constraint
+}
+
+// Layout returns the layout of the puzzle.
+func (p *Puzzle) Layout() (*Layout, error) {
+	var l *Layout
+	var err error
+	if p.Hexagonal {
+		l, err = p.hexLayout()
+	} else {
+		l, err = p.rectLayout()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.CellCharacters {
+		if len(p.Characters) != len(l.Cells) {
+			return nil, fmt.Errorf("crossword: %d cell characters for %d cells", len(p.Characters), len(l.Cells))
+		}
+		for i := range l.Cells {
+			l.Cells[i].Runes = []rune(p.Characters[i])
+		}
+	}
+	for _, i := range p.SolutionMap {
+		if i < 0 || i >= len(l.Cells) {
+			return nil, fmt.Errorf("crossword: solution map has cell %d of %d", i, len(l.Cells))
+		}
+	}
+	l.SolutionMap = p.SolutionMap
+	return l, nil
+}
+
+// Index returns the index of the cell at row r and column c, or -1 if
+// there is none.
+func (l *Layout) Index(r, c int) int {
+	if r < 0 || r >= len(l.Rows) || c < 0 || c >= l.Rows[r] {
+		return -1
+	}
+	i := 0
+	for _, n := range l.Rows[:r] {
+		i += n
+	}
+	return i + c
+}
+
+// Grid splits the runes of the cells, in row-major order, into rows.
+func (l *Layout) Grid(cells []rune) [][]rune {
+	grid := make([][]rune, len(l.Rows))
+	i := 0
+	for r, n := range l.Rows {
+		grid[r] = append([]rune(nil), cells[i:i+n]...)
+		i += n
+	}
+	return grid
+}
+
+// SolutionString reads the grid in the order of the SolutionMap, or
+// in row-major order if there is none.
+func (l *Layout) SolutionString(grid [][]rune) string {
+	var cells []rune
+	for _, row := range grid {
+		cells = append(cells, row...)
+	}
+	if len(l.SolutionMap) == 0 {
+		return string(cells)
+	}
+	s := make([]rune, len(l.SolutionMap))
+	for i, cell := range l.SolutionMap {
+		s[i] = cells[cell]
+	}
+	return string(s)
+}
+
+// rectLayout lays out a rectangular puzzle. Columns are clued by
+// PatternsX and rows by PatternsY, with one list of patterns for each
+// side of the grid that has clues.
+func (p *Puzzle) rectLayout() (*Layout, error) {
+	width, err := axisLength(p.PatternsX, "column")
+	if err != nil {
+		return nil, err
+	}
+	height, err := axisLength(p.PatternsY, "row")
+	if err != nil {
+		return nil, err
+	}
+	l := &Layout{Rows: make([]int, height)}
+	for r := range l.Rows {
+		l.Rows[r] = width
+		cells := make([]int, width)
+		for c := range cells {
+			cells[c] = len(l.Cells)
+			l.Cells = append(l.Cells, Cell{Row: r, Col: c, X: c, Y: r, Z: -1})
+		}
+		l.Lines = append(l.Lines, Line{AxisY, r, cells, clues(p.PatternsY, r)})
+	}
+	for c := 0; c < width; c++ {
+		cells := make([]int, height)
+		for r := range cells {
+			cells[r] = r*width + c
+		}
+		l.Lines = append(l.Lines, Line{AxisX, c, cells, clues(p.PatternsX, c)})
+	}
+	return l, nil
+}
+
+// hexLayout lays out a hexagonal puzzle with sides of Size cells. Row
+// r has Size+r cells in the top half and shrinks symmetrically in the
+// bottom half. Giving each cell the coordinate x = c + max(0, r-Size+1)
+// for column c of row r, the three axes are:
+//
+//	PatternsY  rows, read left to right
+//	PatternsX  cells of equal x, read top to bottom
+//	PatternsZ  cells of equal r-x, read bottom to top, with the line
+//	           along the lower left edge first
+//
+// Each axis has 2*Size-1 lines.
+func (p *Puzzle) hexLayout() (*Layout, error) {
+	n, err := axisLength(p.PatternsY, "row")
+	if err != nil {
+		return nil, err
+	}
+	size := p.Size
+	if size == 0 {
+		size = (n + 1) / 2
+	}
+	if n != 2*size-1 {
+		return nil, fmt.Errorf("crossword: %d row patterns for hexagon of size %d", n, size)
+	}
+	for _, axis := range []struct {
+		patterns [][]string
+		name     string
+	}{{p.PatternsX, "x"}, {p.PatternsZ, "z"}} {
+		m, err := axisLength(axis.patterns, axis.name)
+		if err != nil {
+			return nil, err
+		}
+		if m != n {
+			return nil, fmt.Errorf("crossword: %d %s patterns for hexagon of size %d", m, axis.name, size)
+		}
+	}
+
+	l := &Layout{Hexagonal: true, Size: size, Rows: make([]int, n)}
+	xs := make([][]int, n)
+	zs := make([][]int, n)
+	for r := range l.Rows {
+		offset := 0
+		if r >= size {
+			offset = r - size + 1
+		}
+		l.Rows[r] = size + r - 2*offset
+		cells := make([]int, l.Rows[r])
+		for c := range cells {
+			cell := len(l.Cells)
+			cells[c] = cell
+			x := c + offset
+			z := size - 1 - (r - x)
+			l.Cells = append(l.Cells, Cell{Row: r, Col: c, X: x, Y: r, Z: z})
+			xs[x] = append(xs[x], cell)
+			zs[z] = append([]int{cell}, zs[z]...)
+		}
+		l.Lines = append(l.Lines, Line{AxisY, r, cells, clues(p.PatternsY, r)})
+	}
+	for x, cells := range xs {
+		l.Lines = append(l.Lines, Line{AxisX, x, cells, clues(p.PatternsX, x)})
+	}
+	for z, cells := range zs {
+		l.Lines = append(l.Lines, Line{AxisZ, z, cells, clues(p.PatternsZ, z)})
+	}
+	return l, nil
+}
+
+// axisLength returns the number of lines on an axis and checks that
+// every side of the axis has a pattern for each line.
+func axisLength(axis [][]string, name string) (int, error) {
+	if len(axis) == 0 || len(axis[0]) == 0 {
+		return 0, fmt.Errorf("crossword: puzzle has no %s patterns", name)
+	}
+	n := len(axis[0])
+	for _, side := range axis[1:] {
+		if len(side) != n {
+			return 0, fmt.Errorf("crossword: %s patterns have lengths %d and %d", name, n, len(side))
+		}
+	}
+	return n, nil
+}
+
+// clues returns the patterns for line i from every side of an axis.
+func clues(axis [][]string, i int) []string {
+	var exprs []string
+	for _, side := range axis {
+		exprs = append(exprs, side[i])
+	}
+	return exprs
+}
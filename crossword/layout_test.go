@@ -0,0 +1,131 @@
+package crossword
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andrewarchi/regexp-crossword/regexp"
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+func TestHexLayout(t *testing.T) {
+	l, err := mitPuzzle.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Hexagonal || l.Size != 7 {
+		t.Errorf("got hexagonal %t, size %d, want true, 7", l.Hexagonal, l.Size)
+	}
+	var cells []rune
+	for r, row := range mitSolution {
+		if len(row) != l.Rows[r] {
+			t.Errorf("row %d: got length %d, want %d", r, l.Rows[r], len(row))
+		}
+		cells = append(cells, []rune(row)...)
+	}
+	if len(l.Lines) != 39 {
+		t.Fatalf("got %d lines, want 39", len(l.Lines))
+	}
+	for _, line := range l.Lines {
+		s := make([]rune, len(line.Cells))
+		for i, cell := range line.Cells {
+			s[i] = cells[cell]
+			c := l.Cells[cell]
+			if k := [...]int{c.X, c.Y, c.Z}[line.Axis]; k != line.Index {
+				t.Errorf("cell %d is on %s line %d, want %d", cell, line.Axis, k, line.Index)
+			}
+		}
+		re, err := syntax.Parse(line.Patterns[0], parseFlags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !syntax.MatchRunes(re.Simplify(), s) {
+			t.Errorf("%q does not match %#q", string(s), line.Patterns[0])
+		}
+		full := regexp.MustCompileFlags(`^(?:`+line.Patterns[0]+`)$`, parseFlags)
+		if !full.MatchString(string(s)) {
+			t.Errorf("%q does not match %#q with regexp", string(s), line.Patterns[0])
+		}
+	}
+	for i, c := range l.Cells {
+		if j := l.Index(c.Row, c.Col); j != i {
+			t.Errorf("Index(%d, %d) = %d, want %d", c.Row, c.Col, j, i)
+		}
+	}
+}
+
+func TestRectLayout(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{"a", "b", "c"}, {"A", "B", "C"}},
+		PatternsY: [][]string{{"x", "y"}},
+	}
+	l, err := p.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{3, 3}; !reflect.DeepEqual(l.Rows, want) {
+		t.Errorf("got rows %v, want %v", l.Rows, want)
+	}
+	if c, want := l.Cells[4], (Cell{Row: 1, Col: 1, X: 1, Y: 1, Z: -1}); !reflect.DeepEqual(c, want) {
+		t.Errorf("got cell %+v, want %+v", c, want)
+	}
+	want := []Line{
+		{AxisY, 0, []int{0, 1, 2}, []string{"x"}},
+		{AxisY, 1, []int{3, 4, 5}, []string{"y"}},
+		{AxisX, 0, []int{0, 3}, []string{"a", "A"}},
+		{AxisX, 1, []int{1, 4}, []string{"b", "B"}},
+		{AxisX, 2, []int{2, 5}, []string{"c", "C"}},
+	}
+	if !reflect.DeepEqual(l.Lines, want) {
+		t.Errorf("got lines %v, want %v", l.Lines, want)
+	}
+	if i := l.Index(1, 3); i != -1 {
+		t.Errorf("Index(1, 3) = %d, want -1", i)
+	}
+	grid := l.Grid([]rune("abcdef"))
+	if want := [][]rune{[]rune("abc"), []rune("def")}; !reflect.DeepEqual(grid, want) {
+		t.Errorf("got grid %q, want %q", grid, want)
+	}
+	if s := l.SolutionString(grid); s != "abcdef" {
+		t.Errorf("got solution %q, want %q", s, "abcdef")
+	}
+
+	p.SolutionMap = []int{5, 4, 3, 2, 1, 0}
+	p.Characters = []string{"a", "b", "c", "d", "e", "fg"}
+	l, err = p.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := l.SolutionString(grid); s != "fedcba" {
+		t.Errorf("got solution %q, want %q", s, "fedcba")
+	}
+	// One string per cell is still an alphabet for every cell unless
+	// CellCharacters says otherwise.
+	if r := l.Cells[5].Runes; r != nil {
+		t.Errorf("got runes %q for cell 5, want none", string(r))
+	}
+	p.CellCharacters = true
+	l, err = p.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := l.Cells[5].Runes; string(r) != "fg" {
+		t.Errorf("got runes %q for cell 5, want %q", string(r), "fg")
+	}
+	p.Characters = p.Characters[:5]
+	if _, err := p.Layout(); err == nil {
+		t.Error("expected error for cell characters of the wrong length")
+	}
+	p.Characters = nil
+	p.CellCharacters = false
+
+	p.SolutionMap = []int{6}
+	if _, err := p.Layout(); err == nil {
+		t.Error("expected error for solution map out of range")
+	}
+	p.SolutionMap = nil
+	p.PatternsX[1] = p.PatternsX[1][:2]
+	if _, err := p.Layout(); err == nil {
+		t.Error("expected error for mismatched sides")
+	}
+}
@@ -0,0 +1,199 @@
+package crossword
+
+import (
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+// A domainLine is the input of a syntax.Matcher that finds the runes
+// of a line's domains that appear in some match of a pattern with
+// backreferences, word boundaries or lookarounds. Its positions hold
+// domains rather than runes: each is narrowed by the literals and
+// classes matched there, and a backreference makes the positions that
+// it repeats equal, merging their domains. Every way to match the
+// pattern's structure that leaves no domain empty stands for a set of
+// matching strings, so the union of the final domains over all such
+// matches is exactly the support of the pattern. Lookarounds and
+// atomic groups are the exception: until every position is fixed, the
+// matcher assumes that a negative lookaround holds and lets a positive
+// one or an atomic group use any match of its subexpression, so the
+// support may be larger. So may a backreference that ignores case, as
+// described on unionFold.
+type domainLine struct {
+	s      *solver
+	p      *pattern
+	cells  []int
+	n      int
+	dom    [][]uint64 // domain of each position, valid at roots
+	parent []int      // union-find over equal positions
+	trail  []undo
+	sup    []uint64
+	found  bool
+}
+
+// An undo restores a domain or, if dom is nil, a parent link.
+type undo struct {
+	pos int
+	dom []uint64
+}
+
+// matchBudget bounds the steps of a matcher over a domainLine.
+// Patterns whose matches have too many shapes to enumerate are left
+// to the program, which approximates them.
+const matchBudget = 100000
+
+// supportTree computes the support of the pattern over the domains of
+// cells as described on domainLine. It reports ok false if there is
+// no match, and done false if it ran out of budget, in which case the
+// result is meaningless.
+func (p *pattern) supportTree(s *solver, cells []int) (sup []uint64, ok, done bool) {
+	l := newDomainLine(s, p, cells)
+	l.sup = p.sup
+	for i := range l.sup {
+		l.sup[i] = 0
+	}
+	m := syntax.NewMatcher(p.re, l, matchBudget)
+	m.Match(l.collect)
+	if m.Exhausted() {
+		return nil, false, false
+	}
+	return l.sup, l.found, true
+}
+
+// newDomainLine returns the current domains of cells as the input for
+// matching the pattern.
+func newDomainLine(s *solver, p *pattern, cells []int) *domainLine {
+	n := len(cells)
+	l := &domainLine{
+		s:      s,
+		p:      p,
+		cells:  cells,
+		n:      n,
+		dom:    make([][]uint64, n),
+		parent: make([]int, n),
+	}
+	for i, cell := range cells {
+		l.dom[i] = s.dom(cell)
+		l.parent[i] = i
+	}
+	return l
+}
+
+// collect records the domains of a complete match. It stops the
+// enumeration once every rune of every domain is supported.
+func (l *domainLine) collect(i int) bool {
+	if i != l.n {
+		return false
+	}
+	l.found = true
+	full := true
+	for pos := 0; pos < l.n; pos++ {
+		d := l.dom[l.find(pos)]
+		row := l.sup[pos*l.s.words : (pos+1)*l.s.words]
+		for j := range row {
+			row[j] |= d[j]
+			if row[j] != l.s.dom(l.cells[pos])[j] {
+				full = false
+			}
+		}
+	}
+	return full
+}
+
+func (l *domainLine) find(pos int) int {
+	for l.parent[pos] != pos {
+		pos = l.parent[pos]
+	}
+	return pos
+}
+
+// restrict narrows the domain of pos to the runes in mask. It reports
+// false if no rune remains.
+func (l *domainLine) restrict(pos int, mask []uint64) bool {
+	r := l.find(pos)
+	d := l.dom[r]
+	nd := make([]uint64, len(d))
+	changed, empty := false, true
+	for j := range d {
+		nd[j] = d[j] & mask[j]
+		changed = changed || nd[j] != d[j]
+		empty = empty && nd[j] == 0
+	}
+	if empty {
+		return false
+	}
+	if changed {
+		l.trail = append(l.trail, undo{r, d})
+		l.dom[r] = nd
+	}
+	return true
+}
+
+// union makes positions a and b equal. It reports false if their
+// domains have no rune in common.
+func (l *domainLine) union(a, b int) bool {
+	ra, rb := l.find(a), l.find(b)
+	if ra == rb {
+		return true
+	}
+	if !l.restrict(ra, l.dom[rb]) {
+		return false
+	}
+	l.trail = append(l.trail, undo{rb, nil})
+	l.parent[rb] = ra
+	return true
+}
+
+// unionFold narrows positions a and b to the runes that equal some rune
+// of the other's domain up to case. Unlike union, it does not tie the
+// positions together, so later narrowing of one leaves the other as it
+// is and the support may be larger.
+func (l *domainLine) unionFold(a, b int) bool {
+	return l.restrict(a, l.s.foldDom(l.dom[l.find(b)])) &&
+		l.restrict(b, l.s.foldDom(l.dom[l.find(a)]))
+}
+
+func (l *domainLine) Len() int { return l.n }
+
+// Known reports whether every position has a single rune.
+func (l *domainLine) Known() bool {
+	for pos := 0; pos < l.n; pos++ {
+		if size(l.dom[l.find(pos)]) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *domainLine) Narrow(i int, re *syntax.Regexp, j int) bool {
+	return l.restrict(i, l.p.masks(l.s, re)[j])
+}
+
+func (l *domainLine) NarrowWord(i int, word bool) bool {
+	w, other := l.p.wordMasks(l.s)
+	if word {
+		return l.restrict(i, w)
+	}
+	return l.restrict(i, other)
+}
+
+func (l *domainLine) Equal(i, j int, fold bool) bool {
+	if fold {
+		return l.unionFold(i, j)
+	}
+	return l.union(i, j)
+}
+
+func (l *domainLine) Mark() int { return len(l.trail) }
+
+// Undo reverts the trail to length mark.
+func (l *domainLine) Undo(mark int) {
+	for len(l.trail) > mark {
+		u := l.trail[len(l.trail)-1]
+		l.trail = l.trail[:len(l.trail)-1]
+		if u.dom != nil {
+			l.dom[u.pos] = u.dom
+		} else {
+			l.parent[u.pos] = u.pos
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package crossword
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+// ErrAmbiguous is returned when a puzzle must have a unique solution
+// but has more than one.
+var ErrAmbiguous = errors.New("crossword: more than one solution")
+
+// Minimize returns a copy of the puzzle with each pattern made as
+// short and as loose as it can be while the puzzle keeps its unique
+// solution. The puzzle must have exactly one solution.
+//
+// Patterns are minimized one at a time, in the order of the layout's
+// lines. Each step tries the variants of the pattern that differ from
+// it at a single node, shortest first:
+//
+//   - a subexpression replaced by .*
+//   - an alternative or a factor of a concatenation dropped
+//   - a repetition replaced by its operand
+//   - a rune of a class dropped, which widens a negated class
+//   - a class or a rune of a literal replaced by .
+//   - a group unwrapped, in patterns without backreferences
+//
+// and keeps the first that the solution still matches and that leaves
+// the solution unique. Only patterns that change are rewritten, so
+// the others keep the author's spelling. Since dropping runes from the
+// patterns would shrink the alphabet of a puzzle without Characters,
+// the minimized puzzle lists the original alphabet in Characters.
+func (p *Puzzle) Minimize() (*Puzzle, error) {
+	grids, err := p.Solutions(2)
+	if err != nil {
+		return nil, err
+	}
+	switch len(grids) {
+	case 0:
+		return nil, ErrNoSolution
+	case 2:
+		return nil, ErrAmbiguous
+	}
+	l, err := p.Layout()
+	if err != nil {
+		return nil, err
+	}
+	var cells []rune
+	for _, row := range grids[0] {
+		cells = append(cells, row...)
+	}
+
+	q := *p
+	if len(q.Characters) == 0 {
+		runes, err := p.alphabet()
+		if err != nil {
+			return nil, err
+		}
+		q.Characters = []string{string(runes)}
+	}
+	q.PatternsX = copyPatterns(p.PatternsX)
+	q.PatternsY = copyPatterns(p.PatternsY)
+	q.PatternsZ = copyPatterns(p.PatternsZ)
+	for _, line := range l.Lines {
+		axis := map[Axis][][]string{AxisX: q.PatternsX, AxisY: q.PatternsY, AxisZ: q.PatternsZ}[line.Axis]
+		text := make([]rune, len(line.Cells))
+		for i, cell := range line.Cells {
+			text[i] = cells[cell]
+		}
+		for _, side := range axis {
+			expr := &side[line.Index]
+			for {
+				re, err := syntax.Parse(*expr, parseFlags)
+				if err != nil {
+					return nil, &PatternError{*expr, err}
+				}
+				if !q.minimizeStep(expr, re, text) {
+					break
+				}
+			}
+		}
+	}
+	return &q, nil
+}
+
+// minimizeStep replaces *expr with its first smaller variant that
+// keeps the solution unique and reports whether there was one.
+func (p *Puzzle) minimizeStep(expr *string, re *syntax.Regexp, text []rune) bool {
+	type variant struct {
+		expr       string
+		length, sp int
+	}
+	length, sp := len(patternString(re)), specificity(re)
+	var vs []variant
+	seen := make(map[string]bool)
+	for _, v := range variants(re, !re.HasOp(syntax.OpBackref)) {
+		s := patternString(v)
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		// Reparse the variant, which also rejects references to
+		// groups that it dropped.
+		v, err := syntax.Parse(s, parseFlags)
+		if err != nil {
+			continue
+		}
+		vl, vsp := len(s), specificity(v)
+		if vl > length || vl == length && vsp >= sp {
+			continue
+		}
+		if syntax.MatchRunes(v.Simplify(), text) {
+			vs = append(vs, variant{s, vl, vsp})
+		}
+	}
+	sort.SliceStable(vs, func(i, j int) bool {
+		if vs[i].length != vs[j].length {
+			return vs[i].length < vs[j].length
+		}
+		return vs[i].sp < vs[j].sp
+	})
+	old := *expr
+	for _, v := range vs {
+		*expr = v.expr
+		if grids, err := p.Solutions(2); err == nil && len(grids) == 1 {
+			return true
+		}
+	}
+	*expr = old
+	return false
+}
+
+func copyPatterns(axis [][]string) [][]string {
+	if axis == nil {
+		return nil
+	}
+	c := make([][]string, len(axis))
+	for i, side := range axis {
+		c[i] = append([]string(nil), side...)
+	}
+	return c
+}
+
+// variants returns copies of re that each differ from it at one node,
+// as described on Minimize.
+func variants(re *syntax.Regexp, unwrap bool) []*syntax.Regexp {
+	var vs []*syntax.Regexp
+	if !isDotStar(re) {
+		vs = append(vs, dotStar())
+	}
+	switch re.Op {
+	case syntax.OpAlternate, syntax.OpConcat:
+		for i := range re.Sub {
+			subs := append(append([]*syntax.Regexp(nil), re.Sub[:i]...), re.Sub[i+1:]...)
+			vs = append(vs, withSubs(re, subs))
+		}
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		vs = append(vs, re.Sub[0])
+	case syntax.OpCapture:
+		if unwrap {
+			vs = append(vs, re.Sub[0])
+		}
+	case syntax.OpCharClass:
+		vs = append(vs, &syntax.Regexp{Op: syntax.OpAnyCharNotNL})
+		negated := len(re.Rune) != 0 && re.Rune[0] == 0
+		for _, r := range classRunes(re.Rune, negated) {
+			var class []rune
+			if negated {
+				class = addRange(re.Rune, r)
+			} else {
+				class = removeRune(re.Rune, r)
+			}
+			switch {
+			case len(class) == 2 && class[0] == class[1]:
+				vs = append(vs, &syntax.Regexp{Op: syntax.OpLiteral, Flags: re.Flags, Rune: class[:1]})
+			case len(class) != 0:
+				vs = append(vs, &syntax.Regexp{Op: syntax.OpCharClass, Flags: re.Flags, Rune: class})
+			}
+		}
+	case syntax.OpLiteral:
+		for i := range re.Rune {
+			var subs []*syntax.Regexp
+			if i > 0 {
+				subs = append(subs, &syntax.Regexp{Op: syntax.OpLiteral, Flags: re.Flags, Rune: re.Rune[:i]})
+			}
+			subs = append(subs, &syntax.Regexp{Op: syntax.OpAnyCharNotNL})
+			if i+1 < len(re.Rune) {
+				subs = append(subs, &syntax.Regexp{Op: syntax.OpLiteral, Flags: re.Flags, Rune: re.Rune[i+1:]})
+			}
+			if len(subs) == 1 {
+				vs = append(vs, subs[0])
+			} else {
+				vs = append(vs, &syntax.Regexp{Op: syntax.OpConcat, Sub: subs})
+			}
+		}
+	}
+	for i, sub := range re.Sub {
+		for _, v := range variants(sub, unwrap) {
+			subs := append([]*syntax.Regexp(nil), re.Sub...)
+			subs[i] = v
+			vs = append(vs, withSubs(re, subs))
+		}
+	}
+	return vs
+}
+
+// withSubs returns a copy of re with subs, or the lone sub of an
+// alternation or concatenation.
+func withSubs(re *syntax.Regexp, subs []*syntax.Regexp) *syntax.Regexp {
+	if re.Op == syntax.OpAlternate || re.Op == syntax.OpConcat {
+		switch len(subs) {
+		case 0:
+			return &syntax.Regexp{Op: syntax.OpEmptyMatch}
+		case 1:
+			return subs[0]
+		}
+	}
+	c := *re
+	c.Sub = subs
+	return &c
+}
+
+func dotStar() *syntax.Regexp {
+	return &syntax.Regexp{Op: syntax.OpStar, Sub: []*syntax.Regexp{{Op: syntax.OpAnyCharNotNL}}}
+}
+
+func isDotStar(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar && re.Sub[0].Op == syntax.OpAnyCharNotNL
+}
+
+// specificity counts the runes that re names.
+func specificity(re *syntax.Regexp) int {
+	n := 0
+	switch re.Op {
+	case syntax.OpLiteral:
+		n = len(re.Rune)
+	case syntax.OpCharClass:
+		n = 1
+	}
+	for _, sub := range re.Sub {
+		n += specificity(sub)
+	}
+	return n
+}
+
+// classRunes returns the runes listed by a class, or the runes that it
+// excludes if it is negated, up to a small number.
+func classRunes(class []rune, negated bool) []rune {
+	const max = 64
+	var runes []rune
+	if negated {
+		for i := 1; i < len(class) && len(runes) < max; i += 2 {
+			end := unicode.MaxRune
+			if i+1 < len(class) {
+				end = class[i+1] - 1
+			}
+			for r := class[i] + 1; r <= end && len(runes) < max; r++ {
+				runes = append(runes, r)
+			}
+		}
+		return runes
+	}
+	for i := 0; i < len(class) && len(runes) < max; i += 2 {
+		for r := class[i]; r <= class[i+1] && len(runes) < max; r++ {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// addRange adds r to a class, merging ranges.
+func addRange(class []rune, r rune) []rune {
+	ranges := append(append([]rune(nil), class...), r, r)
+	for i := len(ranges) - 2; i >= 2 && ranges[i] < ranges[i-2]; i -= 2 {
+		ranges[i], ranges[i+1], ranges[i-2], ranges[i-1] = ranges[i-2], ranges[i-1], ranges[i], ranges[i+1]
+	}
+	out := ranges[:2]
+	for i := 2; i < len(ranges); i += 2 {
+		if n := len(out); ranges[i] <= out[n-1]+1 {
+			if ranges[i+1] > out[n-1] {
+				out[n-1] = ranges[i+1]
+			}
+			continue
+		}
+		out = append(out, ranges[i], ranges[i+1])
+	}
+	return out
+}
+
+// removeRune removes r from a class.
+func removeRune(class []rune, r rune) []rune {
+	var out []rune
+	for i := 0; i < len(class); i += 2 {
+		lo, hi := class[i], class[i+1]
+		if r < lo || r > hi {
+			out = append(out, lo, hi)
+			continue
+		}
+		if lo < r {
+			out = append(out, lo, r-1)
+		}
+		if r < hi {
+			out = append(out, r+1, hi)
+		}
+	}
+	return out
+}
+
+// patternString formats re in the style of the site's patterns, with
+// a plain dot for any rune.
+func patternString(re *syntax.Regexp) string {
+	return strings.ReplaceAll(re.String(), "(?-s:.)", ".")
+}
@@ -0,0 +1,81 @@
+package crossword
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	for i, test := range []struct {
+		Puzzle   Puzzle
+		Solution []string
+	}{
+		{Puzzle{
+			PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+			PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+		}, []string{"HE", "LP"}},
+		{Puzzle{
+			PatternsX: [][]string{{`A+B+A`, `(B|C)\1*`, `[AB]*`}, {`.*`, `.C.`, `(A|B)B\1`}},
+			PatternsY: [][]string{{`(.).\1`, `[^A]C.`, `A.*`}},
+		}, []string{"ACA", "BCB", "ACA"}},
+		{Puzzle{
+			PatternsX: [][]string{{`AB|BA`, `.A.`, `B*A`}},
+			PatternsY: [][]string{{`A.`, `(B)A\1`, `[^A]+A`}},
+			PatternsZ: [][]string{{`(.)\1`, `A+`, `[AB]B`}},
+			Hexagonal: true,
+		}, []string{"AB", "BAB", "BA"}},
+	} {
+		q, err := test.Puzzle.Minimize()
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		grids, err := q.Solutions(2)
+		if err != nil || len(grids) != 1 || !reflect.DeepEqual(gridStrings(grids[0]), test.Solution) {
+			t.Errorf("test %d: minimized puzzle has solutions %q, %v, want only %q", i, grids, err, test.Solution)
+		}
+		if before, after := patternLength(&test.Puzzle), patternLength(q); after >= before {
+			t.Errorf("test %d: patterns grew from %d to %d runes", i, before, after)
+		}
+		if reflect.DeepEqual(q.PatternsX, test.Puzzle.PatternsX) && reflect.DeepEqual(q.PatternsY, test.Puzzle.PatternsY) {
+			t.Errorf("test %d: minimized puzzle aliases or equals the original", i)
+		}
+		// Minimizing again finds nothing more to do.
+		r, err := q.Minimize()
+		if err != nil || !reflect.DeepEqual(r, q) {
+			t.Errorf("test %d: second minimization changed %+v to %+v (%v)", i, q, r, err)
+		}
+	}
+
+	ambiguous := Puzzle{
+		PatternsX: [][]string{{`[AB]+`, `[AB]+`}},
+		PatternsY: [][]string{{`AB|BA`, `AB|BA`}},
+	}
+	if _, err := ambiguous.Minimize(); err != ErrAmbiguous {
+		t.Errorf("got error %v, want %v", err, ErrAmbiguous)
+	}
+}
+
+func patternLength(p *Puzzle) int {
+	n := 0
+	for _, axis := range [][][]string{p.PatternsX, p.PatternsY, p.PatternsZ} {
+		for _, side := range axis {
+			for _, expr := range side {
+				n += len(expr)
+			}
+		}
+	}
+	return n
+}
+
+func TestClassEdits(t *testing.T) {
+	if got, want := addRange([]rune{0, 'A' - 1, 'C' + 1, 'Z'}, 'B'), []rune{0, 'A' - 1, 'B', 'B', 'D', 'Z'}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addRange: got %q, want %q", got, want)
+	}
+	if got, want := addRange([]rune{0, 'A', 'C', 'Z'}, 'B'), []rune{0, 'Z'}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addRange: got %q, want %q", got, want)
+	}
+	if got, want := removeRune([]rune{'A', 'C'}, 'B'), []rune{'A', 'A', 'C', 'C'}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removeRune: got %q, want %q", got, want)
+	}
+}
@@ -52,3 +52,19 @@ var mitPuzzle = Puzzle{
 	}},
 	Hexagonal: true,
 }
+
+var mitSolution = []string{
+	"NHPEHAS",
+	"DIOMOMTH",
+	"FOXNXAXPH",
+	"MMOMMMMRHH",
+	"MCXNMMCRXEM",
+	"CMCCCCMMMMMM",
+	"HRXRCMIIIHXLS",
+	"OREOREOREORE",
+	"VCXCCHHMXCC",
+	"RRRRHHHRRU",
+	"NCXDXEXLE",
+	"RRDDMMMM",
+	"GCCHHCC",
+}
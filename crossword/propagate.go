@@ -0,0 +1,372 @@
+package crossword
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+	"github.com/andrewarchi/regexp-crossword/sparse"
+)
+
+// Each cell has a domain: the set of runes that it may still hold,
+// stored as a bitset over the indices of the solver's alphabet. The
+// domains of all cells share one slice, so that the search can save
+// and restore them with a single copy.
+
+// dom returns the domain of cell i.
+func (s *solver) dom(i int) []uint64 {
+	return s.doms[i*s.words : (i+1)*s.words]
+}
+
+// size returns the number of runes in the domain d.
+func size(d []uint64) int {
+	n := 0
+	for _, w := range d {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// index returns the index of r in the alphabet, or -1 if it is not
+// in the alphabet.
+func (s *solver) index(r rune) int {
+	i := sort.Search(len(s.runes), func(i int) bool { return s.runes[i] >= r })
+	if i < len(s.runes) && s.runes[i] == r {
+		return i
+	}
+	return -1
+}
+
+// has reports whether rune r is in the domain of cell i.
+func (s *solver) has(i int, r rune) bool {
+	j := s.index(r)
+	return j >= 0 && s.dom(i)[j/64]&(1<<uint(j%64)) != 0
+}
+
+// first returns the lowest rune in the domain d, or 0 if it is empty.
+func (s *solver) first(d []uint64) rune {
+	for i, w := range d {
+		if w != 0 {
+			return s.runes[i*64+bits.TrailingZeros64(w)]
+		}
+	}
+	return 0
+}
+
+// foldDom returns the runes of the alphabet that equal some rune of
+// the domain d up to case.
+func (s *solver) foldDom(d []uint64) []uint64 {
+	fd := make([]uint64, s.words)
+	for i, r := range s.runes {
+		if d[i/64]&(1<<uint(i%64)) == 0 {
+			continue
+		}
+		lit := &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune{r}, Flags: syntax.FoldCase}
+		for j, f := range s.runes {
+			if lit.MatchRune(0, f) {
+				fd[j/64] |= 1 << uint(j%64)
+			}
+		}
+	}
+	return fd
+}
+
+// propagate narrows the domains of the cells on the queued lines,
+// and on every line that crosses a narrowed cell, until no domain
+// changes. It reports false if some line can no longer be matched.
+func (s *solver) propagate(queue []*line) bool {
+	for _, l := range queue {
+		l.queued = true
+	}
+	ok := true
+	for len(queue) != 0 {
+		l := queue[0]
+		queue = queue[1:]
+		l.queued = false
+		if !ok {
+			continue
+		}
+		var changed []int
+		changed, ok = s.narrow(l)
+		for _, cell := range changed {
+			for _, l2 := range s.cellLines[cell] {
+				if !l2.queued {
+					l2.queued = true
+					queue = append(queue, l2)
+				}
+			}
+		}
+	}
+	return ok
+}
+
+// narrow removes from the domains of the line's cells every rune that
+// does not appear at that position in some match of each pattern. It
+// returns the cells whose domains changed and reports false if some
+// pattern can no longer be matched. Once every cell of the line is
+// fixed, the line is checked exactly, including backreferences, which
+// the compiled programs only approximate.
+func (s *solver) narrow(l *line) ([]int, bool) {
+	var changed []int
+	for again := true; again; {
+		again = false
+		for _, p := range l.patterns {
+			sup, ok := p.supports(s, l.cells)
+			if !ok {
+				return changed, false
+			}
+			for i, cell := range l.cells {
+				d := s.dom(cell)
+				narrowed := false
+				for j := range d {
+					if w := d[j] & sup[i*s.words+j]; w != d[j] {
+						d[j] = w
+						narrowed = true
+					}
+				}
+				if narrowed {
+					changed = append(changed, cell)
+					again = len(l.patterns) > 1
+				}
+			}
+		}
+	}
+	str := make([]rune, len(l.cells))
+	for i, cell := range l.cells {
+		d := s.dom(cell)
+		if size(d) != 1 {
+			return changed, true
+		}
+		str[i] = s.first(d)
+	}
+	for _, p := range l.patterns {
+		if !syntax.MatchRunes(p.re, str) {
+			return changed, false
+		}
+	}
+	return changed, true
+}
+
+// supports returns the runes of each cell's domain that appear at its
+// position in some match of the pattern, as support and supportTree
+// do, and reports false if there is no match.
+func (p *pattern) supports(s *solver, cells []int) ([]uint64, bool) {
+	if p.tree {
+		if sup, ok, done := p.supportTree(s, cells); done {
+			return sup, ok
+		}
+	}
+	return p.support(s, cells)
+}
+
+// support returns, for each position of the line, the runes in the
+// cell's domain that appear at that position in some string matched
+// by the pattern's program, where every position of the string is in
+// its cell's domain. It reports false if there is no such string.
+//
+// The forward pass collects the instructions that are reachable at
+// each position. The backward pass keeps those from which a match is
+// still reachable, and a rune is supported when some live instruction
+// consumes it.
+func (p *pattern) support(s *solver, cells []int) ([]uint64, bool) {
+	n := len(cells)
+	prog := p.prog
+	fwd, live := p.fwd, p.live
+	for _, q := range fwd {
+		q.Reset()
+	}
+	for _, q := range live {
+		q.Reset()
+	}
+
+	p.addThread(fwd[0], uint32(prog.Start), 0, n)
+	for i, cell := range cells {
+		d := s.dom(cell)
+		for _, pc := range fwd[i].Values() {
+			if m := p.runes[pc]; m != nil && intersects(m, d) {
+				p.addThread(fwd[i+1], prog.Inst[pc].Out, i+1, n)
+			}
+		}
+	}
+
+	sup := p.sup
+	for i := range sup {
+		sup[i] = 0
+	}
+	p.addLive(live[n], fwd[n], nil, nil, n, n)
+	for i := n - 1; i >= 0; i-- {
+		if live[i+1].Len() == 0 {
+			return nil, false
+		}
+		d := s.dom(cells[i])
+		row := sup[i*s.words : (i+1)*s.words]
+		for _, pc := range fwd[i].Values() {
+			if m := p.runes[pc]; m != nil && live[i+1].Has(prog.Inst[pc].Out) {
+				for j := range row {
+					row[j] |= m[j] & d[j]
+				}
+			}
+		}
+		p.addLive(live[i], fwd[i], live[i+1], d, i, n)
+	}
+	if !live[0].Has(uint32(prog.Start)) {
+		return nil, false
+	}
+	return sup, true
+}
+
+// addThread adds pc and the instructions reachable from it without
+// consuming a rune to q. Empty-width assertions are evaluated by
+// position alone; word boundaries and lookarounds are assumed to hold,
+// since the neighboring cells may not be fixed.
+func (p *pattern) addThread(q *sparse.Set, pc uint32, pos, n int) {
+	if q.Has(pc) {
+		return
+	}
+	q.Add(pc)
+	inst := &p.prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		p.addThread(q, inst.Out, pos, n)
+		p.addThread(q, inst.Arg, pos, n)
+	case syntax.InstNop, syntax.InstCapture,
+		syntax.InstLookahead, syntax.InstNegLookahead,
+		syntax.InstLookbehind, syntax.InstNegLookbehind:
+		p.addThread(q, inst.Out, pos, n)
+	case syntax.InstEmptyWidth:
+		if emptyHolds(syntax.EmptyOp(inst.Arg), pos, n) {
+			p.addThread(q, inst.Out, pos, n)
+		}
+	}
+}
+
+// addLive adds to live the instructions of fwd, all at position pos,
+// from which a match is reachable, given the domain d of the cell at
+// pos and the live instructions next at position pos+1.
+func (p *pattern) addLive(live, fwd, next *sparse.Set, d []uint64, pos, n int) {
+	// The forward pass visits instructions depth first, so
+	// walking them in reverse usually settles in one pass.
+	pcs := fwd.Values()
+	for again := true; again; {
+		again = false
+		for k := len(pcs) - 1; k >= 0; k-- {
+			pc := pcs[k]
+			if live.Has(pc) {
+				continue
+			}
+			inst := &p.prog.Inst[pc]
+			ok := false
+			switch inst.Op {
+			case syntax.InstMatch:
+				ok = pos == n
+			case syntax.InstAlt, syntax.InstAltMatch:
+				ok = live.Has(inst.Out) || live.Has(inst.Arg)
+			case syntax.InstNop, syntax.InstCapture,
+				syntax.InstLookahead, syntax.InstNegLookahead,
+				syntax.InstLookbehind, syntax.InstNegLookbehind:
+				ok = live.Has(inst.Out)
+			case syntax.InstEmptyWidth:
+				ok = emptyHolds(syntax.EmptyOp(inst.Arg), pos, n) && live.Has(inst.Out)
+			default:
+				m := p.runes[pc]
+				ok = m != nil && next != nil && intersects(m, d) && next.Has(inst.Out)
+			}
+			if ok {
+				live.Add(pc)
+				again = true
+			}
+		}
+	}
+}
+
+func emptyHolds(op syntax.EmptyOp, pos, n int) bool {
+	switch op {
+	case syntax.EmptyBeginLine, syntax.EmptyBeginText:
+		return pos == 0
+	case syntax.EmptyEndLine, syntax.EmptyEndText:
+		return pos == n
+	}
+	return true
+}
+
+// runeMasks returns, for each rune instruction of prog, the set of
+// alphabet runes that it matches, as a domain bitset. Other
+// instructions have nil masks.
+func runeMasks(prog *syntax.Prog, runes []rune, words int) [][]uint64 {
+	masks := make([][]uint64, len(prog.Inst))
+	for pc := range prog.Inst {
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+		default:
+			continue
+		}
+		m := make([]uint64, words)
+		for i, r := range runes {
+			var ok bool
+			switch inst.Op {
+			case syntax.InstRuneAny:
+				ok = true
+			case syntax.InstRuneAnyNotNL:
+				ok = r != '\n'
+			default:
+				ok = inst.MatchRune(r)
+			}
+			if ok {
+				m[i/64] |= 1 << uint(i%64)
+			}
+		}
+		masks[pc] = m
+	}
+	return masks
+}
+
+// masks returns the alphabet runes that the literal or character
+// class re matches at each position, as domain bitsets.
+func (p *pattern) masks(s *solver, re *syntax.Regexp) [][]uint64 {
+	if m, ok := p.reMasks[re]; ok {
+		return m
+	}
+	width := 1
+	if re.Op == syntax.OpLiteral {
+		width = len(re.Rune)
+	}
+	m := make([][]uint64, width)
+	for j := range m {
+		m[j] = make([]uint64, s.words)
+		for i, r := range s.runes {
+			if re.MatchRune(j, r) {
+				m[j][i/64] |= 1 << uint(i%64)
+			}
+		}
+	}
+	if p.reMasks == nil {
+		p.reMasks = make(map[*syntax.Regexp][][]uint64)
+	}
+	p.reMasks[re] = m
+	return m
+}
+
+// wordMasks returns the alphabet runes that are word characters and
+// those that are not, as domain bitsets.
+func (p *pattern) wordMasks(s *solver) (word, other []uint64) {
+	word = make([]uint64, s.words)
+	other = make([]uint64, s.words)
+	for i, r := range s.runes {
+		if syntax.IsWordChar(r) {
+			word[i/64] |= 1 << uint(i%64)
+		} else {
+			other[i/64] |= 1 << uint(i%64)
+		}
+	}
+	return word, other
+}
+
+func intersects(a, b []uint64) bool {
+	for i := range a {
+		if a[i]&b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package crossword
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+)
+
+func TestPropagate(t *testing.T) {
+	for i, test := range []struct {
+		Puzzle Puzzle
+		Want   []string // candidates of each cell, row-major
+	}{
+		{Puzzle{
+			PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+			PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+		}, []string{"H", "E", "L", "P"}},
+		{Puzzle{
+			PatternsX: [][]string{{`[AB]`, `[BC]`}},
+			PatternsY: [][]string{{`(.)\1`}},
+		}, []string{"B", "B"}},
+		{Puzzle{
+			PatternsX: [][]string{{`[AB]`, `[BC]`, `[AC]`}},
+			PatternsY: [][]string{{`.(.)\1`}},
+		}, []string{"AB", "C", "C"}},
+		{Puzzle{
+			PatternsX: [][]string{{`[aB]`, `[AC]`}},
+			PatternsY: [][]string{{`(.)(?i)\1`}},
+		}, []string{"a", "A"}},
+		{Puzzle{
+			PatternsX: [][]string{{`-`, `.`, `[AB-]`}},
+			PatternsY: [][]string{{`.\b.\B.`}},
+		}, []string{"-", "AB", "AB"}},
+	} {
+		s, err := newSolver(&test.Puzzle)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if !s.propagate(append([]*line(nil), s.lines...)) {
+			t.Errorf("test %d: propagation failed", i)
+			continue
+		}
+		for cell, want := range test.Want {
+			var got []rune
+			for _, r := range s.runes {
+				if s.has(cell, r) {
+					got = append(got, r)
+				}
+			}
+			if string(got) != want {
+				t.Errorf("test %d: cell %d has %q, want %q", i, cell, string(got), want)
+			}
+		}
+	}
+}
+
+// TestSupportsEnumerate checks the support of patterns over a line of
+// free cells against the strings that syntax.Enumerate lists.
+func TestSupportsEnumerate(t *testing.T) {
+	const alphabet = "ABC"
+	for _, expr := range []string{
+		`A*B?C*`,
+		`(A|BC)+`,
+		`[^A]B|C.`,
+		`(.)(.)\2\1`,
+		`(.+)\1`,
+		`.*(.)(?:B|\1)`,
+		`\bA\B.*`,
+		`(?=.*C)[AB].*`,
+		`.*(?<=A)B.*`,
+		`(?>A|B)+C?`,
+	} {
+		re, err := syntax.Parse(expr, parseFlags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 1; n <= 4; n++ {
+			want := make([]string, n)
+			syntax.Enumerate(re.Simplify(), n, []rune(alphabet), func(s string) bool {
+				for i, r := range s {
+					if !strings.ContainsRune(want[i], r) {
+						want[i] += string(r)
+					}
+				}
+				return true
+			})
+			p := &Puzzle{
+				PatternsX:  [][]string{make([]string, n)},
+				PatternsY:  [][]string{{expr}},
+				Characters: []string{alphabet},
+			}
+			s, err := newSolver(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := s.lines[0]
+			if len(l.patterns) == 0 {
+				l = s.lines[len(s.lines)-1]
+			}
+			sup, ok := l.patterns[0].supports(s, l.cells)
+			for i := range l.cells {
+				var got []rune
+				if ok {
+					for j, r := range s.runes {
+						if sup[i*s.words+j/64]&(1<<uint(j%64)) != 0 {
+							got = append(got, r)
+						}
+					}
+				}
+				if string(got) != sortString(want[i]) {
+					t.Errorf("%#q, length %d: cell %d supports %q, want %q", expr, n, i, string(got), sortString(want[i]))
+				}
+			}
+		}
+	}
+}
+
+func sortString(s string) string {
+	runes := []rune(s)
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return string(runes)
+}
@@ -0,0 +1,320 @@
+package crossword
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderOptions control how Render draws a puzzle.
+type RenderOptions struct {
+	HideClues bool // draw the grid alone
+	Blank     rune // drawn for empty cells; a space if 0
+}
+
+// Render draws the puzzle with box-drawing characters, filled in with
+// grid, which may be nil or have 0 in empty cells.
+//
+// A rectangular grid has its row patterns to the left and right of
+// the rows and its column patterns stepped above and below the
+// columns, each joined to its column by a line.
+//
+// A hexagonal grid is drawn with its rows staggered and the edges of
+// its cells between them, so that the X lines run down to the left and
+// the Z lines up to the left. Row patterns are to the left of the
+// rows. The patterns of the X lines are beyond the top and upper right
+// edges and those of the Z lines beyond the bottom and lower right
+// edges, each joined by a diagonal to the cell where its line begins.
+// Patterns longer than maxEdgeClue runes, and those for the far ends
+// of lines, are labeled by axis and line, with a prime for a far end,
+// and listed by label below the grid.
+func (p *Puzzle) Render(w io.Writer, grid [][]rune, opts RenderOptions) error {
+	l, err := p.Layout()
+	if err != nil {
+		return err
+	}
+	if grid != nil {
+		if len(grid) != len(l.Rows) {
+			return fmt.Errorf("crossword: grid has %d rows, want %d", len(grid), len(l.Rows))
+		}
+		for r, row := range grid {
+			if len(row) != l.Rows[r] {
+				return fmt.Errorf("crossword: row %d of grid has %d cells, want %d", r, len(row), l.Rows[r])
+			}
+		}
+	}
+	blank := opts.Blank
+	if blank == 0 {
+		blank = ' '
+	}
+	at := func(r, c int) rune {
+		if grid == nil || grid[r][c] == 0 {
+			return blank
+		}
+		return grid[r][c]
+	}
+	var cv canvas
+	if l.Hexagonal {
+		renderHex(&cv, l, at, !opts.HideClues)
+	} else {
+		renderRect(&cv, l, at, !opts.HideClues)
+	}
+	bw := bufio.NewWriter(w)
+	for _, line := range cv.lines {
+		bw.WriteString(strings.TrimRight(string(line), " "))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func renderRect(cv *canvas, l *Layout, at func(r, c int) rune, clues bool) {
+	var rows, cols []Line
+	for _, line := range l.Lines {
+		if line.Axis == AxisY {
+			rows = append(rows, line)
+		} else {
+			cols = append(cols, line)
+		}
+	}
+	width := l.Rows[0]
+	left := 0
+	if clues {
+		for _, row := range rows {
+			if n := utf8.RuneCountInString(side(row.Patterns, 0)) + 1; n > left {
+				left = n
+			}
+		}
+	}
+	center := func(c int) int { return left + 2 + 4*c }
+
+	y := 0
+	if clues && hasSide(cols, 0) {
+		for k, col := range cols {
+			for j := 0; j < k; j++ {
+				cv.set(y, center(j), "│")
+			}
+			cv.set(y, center(k), side(col.Patterns, 0))
+			y++
+		}
+	}
+	cv.set(y, left, rule('┌', '┬', '┐', width))
+	y++
+	for r, row := range rows {
+		if r != 0 {
+			cv.set(y, left, rule('├', '┼', '┤', width))
+			y++
+		}
+		if clues {
+			text := side(row.Patterns, 0)
+			cv.set(y, left-1-utf8.RuneCountInString(text), text)
+			cv.set(y, left+4*width+2, rest(row.Patterns))
+		}
+		for c := 0; c < width; c++ {
+			cv.set(y, center(c)-2, "│ "+string(at(r, c)))
+		}
+		cv.set(y, left+4*width, "│")
+		y++
+	}
+	cv.set(y, left, rule('└', '┴', '┘', width))
+	y++
+	if clues && hasSide(cols, 1) {
+		for k := len(cols) - 1; k >= 0; k-- {
+			for j := 0; j < k; j++ {
+				cv.set(y, center(j), "│")
+			}
+			cv.set(y, center(k), rest(cols[k].Patterns))
+			y++
+		}
+	}
+}
+
+// maxEdgeClue is the length, in runes, of the longest pattern that
+// Render draws along the edge of a hexagonal grid. Longer patterns are
+// labeled there instead and listed below the grid.
+const maxEdgeClue = 40
+
+func renderHex(cv *canvas, l *Layout, at func(r, c int) rune, clues bool) {
+	indent := func(r int) int {
+		if d := r - (l.Size - 1); d > 0 {
+			return d
+		}
+		return l.Size - 1 - r
+	}
+
+	// A clue is drawn as is, unless it is long or on a side past the
+	// first, where the lines of the other axes leave no room for it.
+	var legend [][2]string
+	clue := func(line Line, side int) string {
+		expr := line.Patterns[side]
+		if side == 0 && utf8.RuneCountInString(expr) <= maxEdgeClue {
+			return expr
+		}
+		label := fmt.Sprintf("%s%d%s", line.Axis, line.Index, strings.Repeat("'", side))
+		legend = append(legend, [2]string{label, expr})
+		return label
+	}
+
+	// Rows are drawn on every other line, with the edges of their
+	// cells between them. The first pattern of each row is to its
+	// left, that of each X line at its top end and that of each Z line
+	// at its bottom end, each joined to its line by a diagonal. The
+	// diagonals are parallel, so those of the lines to the right end
+	// nearer the grid, below the text of those to the left.
+	type leader struct {
+		x, y  int // where the diagonal leaves the grid
+		end   int // line of the text
+		text  string
+		glyph string
+	}
+	var rowClues []string
+	var xs, zs []leader
+	left := 0
+	top := 0 // line of the top edge of the first row
+	pos := func(cell int) int {
+		c := l.Cells[cell]
+		return left + 2*indent(c.Row) + 4*c.Col
+	}
+	if clues {
+		for _, line := range l.Lines {
+			for side := range line.Patterns {
+				text := clue(line, side)
+				if side != 0 {
+					continue
+				}
+				start := l.Cells[line.Cells[0]]
+				switch line.Axis {
+				case AxisY:
+					rowClues = append(rowClues, text)
+					if n := utf8.RuneCountInString(text) + 1 - 2*indent(line.Index); n > left {
+						left = n
+					}
+				case AxisX:
+					xs = append(xs, leader{x: 2*indent(start.Row) + 4*start.Col + 4, y: 2*start.Row - 1, text: text, glyph: "╱"})
+				case AxisZ:
+					zs = append(zs, leader{x: 2*indent(start.Row) + 4*start.Col + 4, y: 2*start.Row + 3, text: text, glyph: "╲"})
+				}
+			}
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i].x+xs[i].y > xs[j].x+xs[j].y })
+		for i := range xs {
+			xs[i].end = xs[i].y - 1
+			if i > 0 && xs[i-1].end <= xs[i].end {
+				xs[i].end = xs[i-1].end - 1
+			}
+			if -xs[i].end > top {
+				top = -xs[i].end
+			}
+		}
+		sort.Slice(zs, func(i, j int) bool { return zs[i].x-zs[i].y > zs[j].x-zs[j].y })
+		for i := range zs {
+			zs[i].end = zs[i].y + 1
+			if i > 0 && zs[i-1].end >= zs[i].end {
+				zs[i].end = zs[i-1].end + 1
+			}
+		}
+	}
+
+	cell := 0
+	for r, n := range l.Rows {
+		y := top + 2*r + 1
+		if clues {
+			text := rowClues[r]
+			cv.set(y, pos(cell)-1-utf8.RuneCountInString(text), text)
+		}
+		for c := 0; c < n; c++ {
+			x := pos(cell)
+			cv.set(y-1, x+1, "╱ ╲")
+			cv.set(y, x, "│ "+string(at(r, c)))
+			cv.set(y+1, x+1, "╲ ╱")
+			cell++
+		}
+		cv.set(y, pos(cell-1)+4, "│")
+	}
+	for _, ld := range xs {
+		x, y := left+ld.x, top+ld.y
+		for ; y > top+ld.end; x, y = x+1, y-1 {
+			cv.set(y, x, ld.glyph)
+		}
+		cv.set(y, x, ld.text)
+	}
+	for _, ld := range zs {
+		x, y := left+ld.x, top+ld.y
+		for ; y < top+ld.end; x, y = x+1, y+1 {
+			cv.set(y, x, ld.glyph)
+		}
+		cv.set(y, x, ld.text)
+	}
+
+	if len(legend) == 0 {
+		return
+	}
+	labelWidth := 0
+	for _, entry := range legend {
+		if len(entry[0]) > labelWidth {
+			labelWidth = len(entry[0])
+		}
+	}
+	y := len(cv.lines) + 1
+	for _, entry := range legend {
+		cv.set(y, 0, fmt.Sprintf("%-*s  %s", labelWidth, entry[0], entry[1]))
+		y++
+	}
+}
+
+// side returns the pattern of side i, if there is one.
+func side(patterns []string, i int) string {
+	if i < len(patterns) {
+		return patterns[i]
+	}
+	return ""
+}
+
+// rest joins the patterns of the sides after the first.
+func rest(patterns []string) string {
+	if len(patterns) < 2 {
+		return ""
+	}
+	return strings.Join(patterns[1:], "  ")
+}
+
+func hasSide(lines []Line, i int) bool {
+	return len(lines) != 0 && len(lines[0].Patterns) > i
+}
+
+// rule draws a horizontal border of a row of width cells.
+func rule(first, mid, last rune, width int) string {
+	var b strings.Builder
+	b.WriteRune(first)
+	for c := 0; c < width; c++ {
+		if c != 0 {
+			b.WriteRune(mid)
+		}
+		b.WriteString("───")
+	}
+	b.WriteRune(last)
+	return b.String()
+}
+
+// canvas is a grid of runes that grows to fit what is drawn on it.
+type canvas struct {
+	lines [][]rune
+}
+
+// set draws s at line y, starting at column x.
+func (cv *canvas) set(y, x int, s string) {
+	for len(cv.lines) <= y {
+		cv.lines = append(cv.lines, nil)
+	}
+	line := cv.lines[y]
+	for _, r := range s {
+		for len(line) <= x {
+			line = append(line, ' ')
+		}
+		line[x] = r
+		x++
+	}
+	cv.lines[y] = line
+}
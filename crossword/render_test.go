@@ -0,0 +1,107 @@
+package crossword
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	rect := Puzzle{
+		PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}, {`H.`, `.P`}},
+		PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+	}
+	hex := Puzzle{
+		PatternsX: [][]string{{`AB|BA`, `.A.`, `B*A`}},
+		PatternsY: [][]string{{`A.`, `(B)A\1`, `[^A]+A`}},
+		PatternsZ: [][]string{{`(.)\1`, `A+`, `[AB]B`}},
+		Hexagonal: true,
+	}
+	long := Puzzle{
+		PatternsX: [][]string{{`A*`, `B*`, `C*`}, {`.*`, `.*`, `.*`}},
+		PatternsY: [][]string{{`..`, `(?:A|B|C)(?:A|B|C)(?:A|B|C)(?:A|B|C)(?:AA)?`, `..`}},
+		PatternsZ: [][]string{{`A.`, `B.`, `C.`}},
+		Hexagonal: true,
+	}
+	for i, test := range []struct {
+		Puzzle Puzzle
+		Grid   [][]rune
+		Opts   RenderOptions
+		Want   string
+	}{
+		{rect, runeGrid("HE", "LP"), RenderOptions{}, `
+            [^SPEAK]+
+            │   EP|IP|EF
+          ┌───┬───┐
+ HE|LL|O+ │ H │ E │
+          ├───┼───┤
+[PLEASE]+ │ L │ P │
+          └───┴───┘
+            │   .P
+            H.
+`},
+		{rect, nil, RenderOptions{HideClues: true, Blank: '.'}, `
+┌───┬───┐
+│ . │ . │
+├───┼───┤
+│ . │ . │
+└───┴───┘
+`},
+		{hex, runeGrid("AB", "B\x00B", "BA"), RenderOptions{Blank: '.'}, `
+               AB|BA
+              ╱   .A.
+             ╱   ╱
+          ╱ ╲ ╱ ╲   B*A
+      A. │ A │ B │ ╱
+        ╱ ╲ ╱ ╲ ╱ ╲
+(B)A\1 │ B │ . │ B │
+        ╲ ╱ ╲ ╱ ╲ ╱
+  [^A]+A │ B │ A │ ╲
+          ╲ ╱ ╲ ╱   [AB]B
+             ╲   ╲
+              ╲   A+
+               (.)\1
+`},
+		{hex, nil, RenderOptions{HideClues: true}, `
+   ╱ ╲ ╱ ╲
+  │   │   │
+ ╱ ╲ ╱ ╲ ╱ ╲
+│   │   │   │
+ ╲ ╱ ╲ ╱ ╲ ╱
+  │   │   │
+   ╲ ╱ ╲ ╱
+`},
+		{long, nil, RenderOptions{}, `
+           A*
+          ╱   B*
+         ╱   ╱
+      ╱ ╲ ╱ ╲   C*
+  .. │   │   │ ╱
+    ╱ ╲ ╱ ╲ ╱ ╲
+y1 │   │   │   │
+    ╲ ╱ ╲ ╱ ╲ ╱
+  .. │   │   │ ╲
+      ╲ ╱ ╲ ╱   C.
+         ╲   ╲
+          ╲   B.
+           A.
+
+y1   (?:A|B|C)(?:A|B|C)(?:A|B|C)(?:A|B|C)(?:AA)?
+x0'  .*
+x1'  .*
+x2'  .*
+`},
+	} {
+		var buf bytes.Buffer
+		if err := test.Puzzle.Render(&buf, test.Grid, test.Opts); err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if got, want := buf.String(), test.Want[1:]; got != want {
+			t.Errorf("test %d: got\n%s\nwant\n%s", i, got, want)
+		}
+	}
+	var buf bytes.Buffer
+	if err := rect.Render(&buf, runeGrid("HE"), RenderOptions{}); err == nil {
+		t.Error("expected error for short grid")
+	}
+}
@@ -0,0 +1,346 @@
+package crossword
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+	"unicode"
+
+	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
+	"github.com/andrewarchi/regexp-crossword/sparse"
+)
+
+// ErrNoSolution is returned when no grid satisfies every pattern.
+var ErrNoSolution = errors.New("crossword: no solution")
+
+// Solve fills the puzzle so that every line matches each of its
+// patterns in full. Cells are filled from the puzzle's alphabet:
+// the runes in Characters, if set, or otherwise every rune that the
+// patterns name explicitly. With CellCharacters, each cell is further
+// restricted to its own string of Characters, as described on Layout.
+func (p *Puzzle) Solve() ([][]rune, error) {
+	grids, err := p.Solutions(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(grids) == 0 {
+		return nil, ErrNoSolution
+	}
+	return grids[0], nil
+}
+
+// Solutions returns up to limit distinct solutions to the puzzle, or
+// all of them if limit is negative. A puzzle with no solution yields
+// no grids and no error.
+func (p *Puzzle) Solutions(limit int) ([][][]rune, error) {
+	s, err := newSolver(p)
+	if err != nil {
+		return nil, err
+	}
+	var grids [][][]rune
+	if limit == 0 {
+		return grids, nil
+	}
+	s.solve(func() bool {
+		grids = append(grids, s.grid())
+		return len(grids) == limit
+	})
+	return grids, nil
+}
+
+// IsAmbiguous reports whether the puzzle has more than one solution.
+// It returns ErrNoSolution if the puzzle has none.
+func (p *Puzzle) IsAmbiguous() (bool, error) {
+	grids, err := p.Solutions(2)
+	if err != nil {
+		return false, err
+	}
+	if len(grids) == 0 {
+		return false, ErrNoSolution
+	}
+	return len(grids) > 1, nil
+}
+
+// CheckAmbiguous reports whether the Ambiguous flag, as set by the
+// site, agrees with the number of solutions that the puzzle has.
+func (p *Puzzle) CheckAmbiguous() (bool, error) {
+	ambiguous, err := p.IsAmbiguous()
+	if err != nil {
+		return false, err
+	}
+	return ambiguous == p.Ambiguous, nil
+}
+
+// solver searches for solutions by propagating the patterns of each
+// line to the domains of its cells, and by branching on a cell when
+// propagation alone does not fix every cell.
+type solver struct {
+	layout    *Layout
+	lines     []*line
+	patterns  []*pattern
+	cellLines [][]*line // lines through each cell
+	runes     []rune    // alphabet
+	words     int       // length of a domain, in words
+	doms      []uint64  // domain of each cell
+	guesses   int       // candidates tried by search
+}
+
+// A line is a sequence of cells that must match each of its patterns.
+type line struct {
+	cells    []int
+	patterns []*pattern
+	queued   bool // in the propagation queue
+}
+
+// A pattern is a clue compiled for a line of fixed length.
+type pattern struct {
+	expr  string
+	index int            // position among the solver's patterns
+	re    *syntax.Regexp // simplified, for exact matching
+	prog  *syntax.Prog   // fixed length and masked to the alphabet
+	runes [][]uint64     // alphabet runes matched by each instruction
+
+	// Patterns with backreferences or word boundaries are matched
+	// over the syntax tree, since their programs only approximate
+	// them.
+	tree    bool
+	reMasks map[*syntax.Regexp][][]uint64
+
+	// Scratch space for support.
+	fwd, live []*sparse.Set
+	sup       []uint64
+}
+
+func newSolver(p *Puzzle) (*solver, error) {
+	layout, err := p.Layout()
+	if err != nil {
+		return nil, err
+	}
+	runes, err := p.alphabet()
+	if err != nil {
+		return nil, err
+	}
+	class := runeClass(runes)
+
+	n := len(layout.Cells)
+	words := (len(runes) + 63) / 64
+	s := &solver{
+		layout:    layout,
+		cellLines: make([][]*line, n),
+		runes:     runes,
+		words:     words,
+		doms:      make([]uint64, n*words),
+	}
+	for i, cell := range layout.Cells {
+		d := s.dom(i)
+		for j, r := range runes {
+			if cell.Runes == nil || containsRune(cell.Runes, r) {
+				d[j/64] |= 1 << uint(j%64)
+			}
+		}
+	}
+	for _, l := range layout.Lines {
+		if err := s.addLine(l.Cells, l.Patterns, class); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *solver) addLine(cells []int, exprs []string, class []rune) error {
+	l := &line{cells: cells}
+	for _, expr := range exprs {
+		if expr == "" {
+			// Blank clues place no constraint on the line.
+			continue
+		}
+		re, err := syntax.Parse(expr, parseFlags)
+		if err != nil {
+			return &PatternError{expr, err}
+		}
+		re = re.Simplify()
+		n := len(cells)
+		sized, err := syntax.ConstrainLength(re.Mask(class), n, n+1)
+		if err != nil {
+			return &PatternError{expr, err}
+		}
+		prog, err := syntax.Compile(sized.Size(n))
+		if err != nil {
+			return &PatternError{expr, err}
+		}
+		pat := &pattern{
+			expr:  expr,
+			index: len(s.patterns),
+			re:    re,
+			prog:  prog,
+			runes: runeMasks(prog, s.runes, s.words),
+			tree:  needsTree(re),
+			fwd:   make([]*sparse.Set, n+1),
+			live:  make([]*sparse.Set, n+1),
+			sup:   make([]uint64, n*s.words),
+		}
+		for i := range pat.fwd {
+			pat.fwd[i] = sparse.NewSet(uint32(len(prog.Inst)))
+			pat.live[i] = sparse.NewSet(uint32(len(prog.Inst)))
+		}
+		l.patterns = append(l.patterns, pat)
+		s.patterns = append(s.patterns, pat)
+	}
+	s.lines = append(s.lines, l)
+	for _, cell := range cells {
+		s.cellLines[cell] = append(s.cellLines[cell], l)
+	}
+	return nil
+}
+
+// solve propagates every line and then searches, calling found for
+// each solution until found returns true.
+func (s *solver) solve(found func() bool) {
+	if s.propagate(append([]*line(nil), s.lines...)) {
+		s.search(found)
+	}
+}
+
+// search calls found for each solution consistent with the current
+// domains, which must already be propagated, until found returns
+// true. It branches on the unfixed cell with the fewest candidates.
+// It reports whether found stopped the search. The domains are left
+// unchanged.
+func (s *solver) search(found func() bool) bool {
+	cell, min := -1, 0
+	for i := range s.cellLines {
+		if n := size(s.dom(i)); n > 1 && (cell < 0 || n < min) {
+			cell, min = i, n
+		}
+	}
+	if cell < 0 {
+		// Every cell is fixed, so every line was checked exactly
+		// when its last cell was fixed.
+		return found()
+	}
+	saved := append([]uint64(nil), s.doms...)
+	cand := append([]uint64(nil), s.dom(cell)...)
+	for j, w := range cand {
+		for w != 0 {
+			b := uint(bits.TrailingZeros64(w))
+			w &^= 1 << b
+			d := s.dom(cell)
+			for k := range d {
+				d[k] = 0
+			}
+			d[j] = 1 << b
+			s.guesses++
+			stop := s.propagate(append([]*line(nil), s.cellLines[cell]...)) && s.search(found)
+			copy(s.doms, saved)
+			if stop {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *solver) grid() [][]rune {
+	cells := make([]rune, len(s.cellLines))
+	for i := range cells {
+		cells[i] = s.first(s.dom(i))
+	}
+	return s.layout.Grid(cells)
+}
+
+func containsRune(runes []rune, r rune) bool {
+	for _, r2 := range runes {
+		if r2 == r {
+			return true
+		}
+	}
+	return false
+}
+
+// needsTree reports whether re has ops that programs approximate.
+func needsTree(re *syntax.Regexp) bool {
+	return re.NeedsBacktrack() || re.HasOp(syntax.OpWordBoundary, syntax.OpNoWordBoundary)
+}
+
+// alphabet returns the sorted runes that may fill a cell: the runes
+// of Characters, if set, or otherwise the printable runes that the
+// patterns name in literals and character classes. Negated classes
+// contribute the runes that they exclude.
+func (p *Puzzle) alphabet() ([]rune, error) {
+	set := make(map[rune]bool)
+	if len(p.Characters) != 0 {
+		for _, chars := range p.Characters {
+			for _, r := range chars {
+				set[r] = true
+			}
+		}
+	} else {
+		for _, axis := range [3][][]string{p.PatternsX, p.PatternsY, p.PatternsZ} {
+			for _, side := range axis {
+				for _, expr := range side {
+					re, err := syntax.Parse(expr, parseFlags)
+					if err != nil {
+						return nil, &PatternError{expr, err}
+					}
+					addRunes(re, set)
+				}
+			}
+		}
+	}
+	var runes []rune
+	for r := range set {
+		if r != 0 && unicode.IsPrint(r) {
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	return runes, nil
+}
+
+func addRunes(re *syntax.Regexp, set map[rune]bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			set[r] = true
+			if re.Flags&syntax.FoldCase != 0 {
+				for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+					set[f] = true
+				}
+			}
+		}
+	case syntax.OpCharClass:
+		class := re.Rune
+		if len(class) != 0 && class[0] == 0 && class[len(class)-1] == unicode.MaxRune {
+			// Negated class; use the gaps.
+			for i := 1; i < len(class)-1; i += 2 {
+				for r := class[i] + 1; r < class[i+1]; r++ {
+					set[r] = true
+				}
+			}
+			break
+		}
+		for i := 0; i < len(class); i += 2 {
+			for r := class[i]; r <= class[i+1]; r++ {
+				set[r] = true
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		addRunes(sub, set)
+	}
+}
+
+// runeClass converts sorted runes to a character class of range pairs.
+func runeClass(runes []rune) []rune {
+	var class []rune
+	for _, r := range runes {
+		if n := len(class); n != 0 && class[n-1]+1 == r {
+			class[n-1] = r
+		} else {
+			class = append(class, r, r)
+		}
+	}
+	return class
+}
@@ -0,0 +1,129 @@
+package crossword
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	for i, test := range []struct {
+		Puzzle Puzzle
+		Want   []string
+	}{
+		{Puzzle{
+			PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+			PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+		}, []string{"HE", "LP"}},
+		{Puzzle{
+			PatternsX: [][]string{{`A+B+A`, `(B|C)\1*`, `[AB]*`}, {`.*`, `.C.`, `(A|B)B\1`}},
+			PatternsY: [][]string{{`(.).\1`, `[^A]C.`, `A.*`}},
+		}, []string{"ACA", "BCB", "ACA"}},
+		{Puzzle{
+			PatternsX: [][]string{{`AB|BA`, `.A.`, `B*A`}},
+			PatternsY: [][]string{{`A.`, `(B)A\1`, `[^A]+A`}},
+			PatternsZ: [][]string{{`(.)\1`, `A+`, `[AB]B`}},
+			Hexagonal: true,
+		}, []string{"AB", "BAB", "BA"}},
+		{Puzzle{
+			PatternsX:      [][]string{{`.`, `.`}},
+			PatternsY:      [][]string{{`AB|BA`}},
+			Characters:     []string{"B", "AB"},
+			CellCharacters: true,
+		}, []string{"BA"}},
+		{Puzzle{
+			PatternsX: [][]string{{`(?=.*A).+`, `(?!B)..`}},
+			PatternsY: [][]string{{`(?!A).*`, `..(?<!B)`}},
+		}, []string{"BA", "AA"}},
+		{Puzzle{
+			PatternsX: [][]string{{`B?+A`, `(?>B+)`}},
+			PatternsY: [][]string{{`A*+B.*`, `(?>A|BA)B`}},
+		}, []string{"BB", "AB"}},
+		{Puzzle{
+			PatternsX: [][]string{{`.+`, `.+`}},
+			PatternsY: [][]string{{`(?>A|AB)B`, `(?>|A)AB`}},
+		}, []string{"AB", "AB"}},
+	} {
+		grid, err := test.Puzzle.Solve()
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if got := gridStrings(grid); !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("test %d: got %q, want %q", i, got, test.Want)
+		}
+	}
+}
+
+func TestSolveNoSolution(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`A`, `B`}},
+		PatternsY: [][]string{{`AA|BB`}},
+	}
+	if _, err := p.Solve(); err != ErrNoSolution {
+		t.Errorf("got error %v, want %v", err, ErrNoSolution)
+	}
+}
+
+func gridStrings(grid [][]rune) []string {
+	s := make([]string, len(grid))
+	for i, row := range grid {
+		s[i] = string(row)
+	}
+	return s
+}
+
+func TestSolutions(t *testing.T) {
+	p := Puzzle{
+		PatternsX: [][]string{{`[AB]+`, `[AB]+`}},
+		PatternsY: [][]string{{`AB|BA`, `AB|BA`}},
+		Ambiguous: true,
+	}
+	for _, test := range []struct {
+		Limit, Want int
+	}{{-1, 4}, {0, 0}, {3, 3}, {10, 4}} {
+		grids, err := p.Solutions(test.Limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grids) != test.Want {
+			t.Errorf("limit %d: got %d solutions, want %d", test.Limit, len(grids), test.Want)
+		}
+		seen := make(map[string]bool)
+		for _, grid := range grids {
+			key := fmt.Sprint(gridStrings(grid))
+			if seen[key] {
+				t.Errorf("limit %d: duplicate solution %s", test.Limit, key)
+			}
+			seen[key] = true
+		}
+	}
+	if ok, err := p.CheckAmbiguous(); err != nil || !ok {
+		t.Errorf("CheckAmbiguous() = %t, %v, want true", ok, err)
+	}
+	p.Ambiguous = false
+	if ok, err := p.CheckAmbiguous(); err != nil || ok {
+		t.Errorf("CheckAmbiguous() = %t, %v, want false", ok, err)
+	}
+
+	unique := Puzzle{
+		PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+		PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+	}
+	if ambiguous, err := unique.IsAmbiguous(); err != nil || ambiguous {
+		t.Errorf("IsAmbiguous() = %t, %v, want false", ambiguous, err)
+	}
+}
+
+func TestSolveMIT(t *testing.T) {
+	grids, err := mitPuzzle.Solutions(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grids) != 1 {
+		t.Fatalf("got %d solutions, want 1", len(grids))
+	}
+	if got := gridStrings(grids[0]); !reflect.DeepEqual(got, mitSolution) {
+		t.Errorf("got %q, want %q", got, mitSolution)
+	}
+}
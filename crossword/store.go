@@ -0,0 +1,220 @@
+package crossword
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoSnapshot is returned when a store has no snapshots.
+var ErrNoSnapshot = errors.New("crossword: no snapshot")
+
+// Snapshot is the corpus of challenges and player puzzles as fetched
+// at a point in time.
+type Snapshot struct {
+	Time       time.Time   `json:"time"`
+	Challenges []Challenge `json:"challenges"`
+	Puzzles    []Puzzle    `json:"puzzles"`
+}
+
+// Store saves snapshots of the corpus as JSON files in a directory,
+// one per sync, so that the corpus is available offline.
+type Store struct {
+	Dir string
+
+	now func() time.Time // for testing
+}
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+	snapshotLayout = "20060102T150405Z"
+)
+
+// OpenStore returns a store in dir, creating the directory if needed.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// Times returns the times of the snapshots in the store, oldest first.
+func (s *Store) Times() ([]time.Time, error) {
+	f, err := os.Open(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	var times []time.Time
+	for _, name := range names {
+		if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix)
+		t, err := time.Parse(snapshotLayout, stamp)
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	return times, nil
+}
+
+func (s *Store) path(t time.Time) string {
+	return filepath.Join(s.Dir, snapshotPrefix+t.UTC().Format(snapshotLayout)+snapshotSuffix)
+}
+
+// Load reads the snapshot taken at time t.
+func (s *Store) Load(t time.Time) (*Snapshot, error) {
+	f, err := os.Open(s.path(t))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var snap Snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// Latest reads the most recent snapshot. It returns ErrNoSnapshot if
+// the store is empty.
+func (s *Store) Latest() (*Snapshot, error) {
+	times, err := s.Times()
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, ErrNoSnapshot
+	}
+	return s.Load(times[len(times)-1])
+}
+
+// Save writes the snapshot, named by its time to the second. It
+// writes to a temporary file first, so that an interrupted save does
+// not leave a partial snapshot.
+func (s *Store) Save(snap *Snapshot) error {
+	snap.Time = snap.Time.UTC().Truncate(time.Second)
+	f, err := os.CreateTemp(s.Dir, "tmp-*"+snapshotSuffix)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(snap)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(snap.Time))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Sync fetches the corpus with c and compares it with the latest
+// snapshot by the DateUpdated of each puzzle. If any puzzle was added,
+// changed, or unpublished, it saves the corpus as a new snapshot, and
+// otherwise it keeps the latest one. It returns the current snapshot
+// and the differences from the previous one.
+func (s *Store) Sync(ctx context.Context, c *Client) (*Snapshot, *Diff, error) {
+	prev, err := s.Latest()
+	if err == ErrNoSnapshot {
+		prev = &Snapshot{}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	challenges, err := c.GetChallenges(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	puzzles, err := c.GetPlayerPuzzles(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	snap := &Snapshot{Time: now(), Challenges: challenges, Puzzles: puzzles}
+	diff := DiffSnapshots(prev, snap)
+	if diff.Empty() && !prev.Time.IsZero() {
+		return prev, diff, nil
+	}
+	if err := s.Save(snap); err != nil {
+		return nil, nil, err
+	}
+	return snap, diff, nil
+}
+
+// Diff lists the IDs of the puzzles that differ between two
+// snapshots, each sorted.
+type Diff struct {
+	Added       []string // in the new snapshot only
+	Changed     []string // updated since the old snapshot
+	Unpublished []string // missing from the new snapshot or no longer published
+}
+
+// Empty reports whether the snapshots have the same puzzles.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Unpublished) == 0
+}
+
+// DiffSnapshots compares the puzzles of two snapshots, both those in
+// challenges and those submitted by players, by ID. A puzzle has
+// changed if its DateUpdated has.
+func DiffSnapshots(old, new *Snapshot) *Diff {
+	before, after := old.puzzleMap(), new.puzzleMap()
+	d := &Diff{}
+	for id, p := range after {
+		q, ok := before[id]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, id)
+		case q.Published && !p.Published:
+			d.Unpublished = append(d.Unpublished, id)
+		case !q.DateUpdated.Equal(p.DateUpdated.Time):
+			d.Changed = append(d.Changed, id)
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			d.Unpublished = append(d.Unpublished, id)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Changed)
+	sort.Strings(d.Unpublished)
+	return d
+}
+
+// puzzleMap indexes all puzzles in the snapshot by ID.
+func (snap *Snapshot) puzzleMap() map[string]*Puzzle {
+	m := make(map[string]*Puzzle)
+	for i := range snap.Challenges {
+		for j := range snap.Challenges[i].Puzzles {
+			p := &snap.Challenges[i].Puzzles[j]
+			m[p.ID] = p
+		}
+	}
+	for i := range snap.Puzzles {
+		m[snap.Puzzles[i].ID] = &snap.Puzzles[i]
+	}
+	return m
+}
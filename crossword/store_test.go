@@ -0,0 +1,88 @@
+package crossword
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreSync(t *testing.T) {
+	challenges := []Challenge{{ID: "c1", Puzzles: []Puzzle{
+		{ID: "a", DateUpdated: UnixTime{time.Unix(100, 0)}},
+	}}}
+	puzzles := []Puzzle{
+		{ID: "p1", Published: true, DateUpdated: UnixTime{time.Unix(100, 0)}},
+		{ID: "p2", Published: true, DateUpdated: UnixTime{time.Unix(100, 0)}},
+		{ID: "p3", Published: true, DateUpdated: UnixTime{time.Unix(100, 0)}},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/challenges.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(challenges)
+	})
+	mux.HandleFunc("/api/puzzles", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(puzzles)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+
+	s, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	if _, err := s.Latest(); err != ErrNoSnapshot {
+		t.Fatalf("empty store: got error %v, want %v", err, ErrNoSnapshot)
+	}
+	ctx := context.Background()
+	snap1, diff, err := s.Sync(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Diff{Added: []string{"a", "p1", "p2", "p3"}}); !reflect.DeepEqual(diff, want) {
+		t.Errorf("first sync: got %+v, want %+v", diff, want)
+	}
+
+	// Nothing changed, so no snapshot is saved.
+	snap2, diff, err := s.Sync(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() || !snap2.Time.Equal(snap1.Time) {
+		t.Errorf("second sync: got %+v at %v, want no change at %v", diff, snap2.Time, snap1.Time)
+	}
+
+	puzzles[0].DateUpdated = UnixTime{time.Unix(200, 0)}
+	puzzles[1].Published = false
+	puzzles = append(puzzles[:2], Puzzle{ID: "p4", Published: true})
+	_, diff, err = s.Sync(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Diff{Added: []string{"p4"}, Changed: []string{"p1"}, Unpublished: []string{"p2", "p3"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("third sync: got %+v, want %+v", diff, want)
+	}
+	times, err := s.Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Time{snap1.Time, clock}; !reflect.DeepEqual(times, want) {
+		t.Errorf("got snapshots %v, want %v", times, want)
+	}
+	latest, err := s.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest.Puzzles) != 3 || latest.Puzzles[2].ID != "p4" {
+		t.Errorf("latest snapshot has %+v", latest.Puzzles)
+	}
+}
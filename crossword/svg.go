@@ -0,0 +1,220 @@
+package crossword
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVGOptions control how WriteSVG draws a puzzle.
+type SVGOptions struct {
+	// Grid, if set, fills in the cells. Cells that hold 0 are left
+	// empty.
+	Grid [][]rune
+
+	// Candidates writes in each empty cell the runes that remain
+	// possible there after propagating the patterns, as Solve does,
+	// from the filled cells of Grid.
+	Candidates bool
+
+	CellSize float64 // width of a cell; 40 if 0
+}
+
+// WriteSVG draws the puzzle as an SVG image. Rectangular puzzles have
+// square cells and hexagonal puzzles have hexagonal cells, with rows
+// across as in Render. Each pattern is written beyond the end of its
+// line that the pattern's side clues, rotated to run along the line.
+func (p *Puzzle) WriteSVG(w io.Writer, opts SVGOptions) error {
+	l, err := p.Layout()
+	if err != nil {
+		return err
+	}
+	size := opts.CellSize
+	if size == 0 {
+		size = 40
+	}
+	var cells []rune
+	if opts.Grid != nil {
+		if len(opts.Grid) != len(l.Rows) {
+			return fmt.Errorf("crossword: grid has %d rows, want %d", len(opts.Grid), len(l.Rows))
+		}
+		for r, row := range opts.Grid {
+			if len(row) != l.Rows[r] {
+				return fmt.Errorf("crossword: row %d of grid has %d cells, want %d", r, len(row), l.Rows[r])
+			}
+			cells = append(cells, row...)
+		}
+	}
+	var candidates []string
+	if opts.Candidates {
+		if candidates, err = p.candidates(opts.Grid); err != nil {
+			return err
+		}
+	}
+
+	d := &svgDrawing{}
+	centers := make([][2]float64, len(l.Cells))
+	for i, c := range l.Cells {
+		var x, y float64
+		if l.Hexagonal {
+			indent := math.Abs(float64(c.Row - (l.Size - 1)))
+			x = size * (float64(c.Col) + indent/2)
+			y = size * math.Sqrt(3) / 2 * float64(c.Row)
+			r := size / math.Sqrt(3)
+			var pts []string
+			for k := 0; k < 6; k++ {
+				a := math.Pi/6 + math.Pi/3*float64(k)
+				px, py := x+r*math.Cos(a), y+r*math.Sin(a)
+				d.extend(px, py)
+				pts = append(pts, num(px)+","+num(py))
+			}
+			d.add(`<polygon points="%s"/>`, strings.Join(pts, " "))
+		} else {
+			x, y = size*float64(c.Col), size*float64(c.Row)
+			d.extend(x-size/2, y-size/2)
+			d.extend(x+size/2, y+size/2)
+			d.add(`<rect x="%s" y="%s" width="%s" height="%s"/>`,
+				num(x-size/2), num(y-size/2), num(size), num(size))
+		}
+		centers[i] = [2]float64{x, y}
+	}
+
+	letter, small, clue := size/2, size/4, size*0.35
+	for i, c := range centers {
+		switch {
+		case cells != nil && cells[i] != 0:
+			d.add(`<text class="cell" x="%s" y="%s" font-size="%s">%s</text>`,
+				num(c[0]), num(c[1]), num(letter), escape(string(cells[i])))
+		case candidates != nil:
+			d.add(`<text class="candidates" x="%s" y="%s" font-size="%s">%s</text>`,
+				num(c[0]), num(c[1]), num(small), escape(candidates[i]))
+		}
+	}
+
+	for _, line := range l.Lines {
+		dx, dy := lineDirection(line.Axis, l.Hexagonal)
+		first, last := centers[line.Cells[0]], centers[line.Cells[len(line.Cells)-1]]
+		// The first side is written before the first cell, and the
+		// others together after the last cell.
+		d.clue(first[0]-dx*size*0.75, first[1]-dy*size*0.75, -dx, -dy, side(line.Patterns, 0), clue)
+		d.clue(last[0]+dx*size*0.75, last[1]+dy*size*0.75, dx, dy, rest(line.Patterns), clue)
+	}
+
+	margin := size / 2
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s %s %s %s" font-family="monospace">`+"\n",
+		num(d.minX-margin), num(d.minY-margin), num(d.maxX-d.minX+2*margin), num(d.maxY-d.minY+2*margin))
+	bw.WriteString("<style>polygon, rect { fill: none; stroke: black; } " +
+		"text { dominant-baseline: central; } .cell, .candidates { text-anchor: middle; } " +
+		".candidates { fill: gray; }</style>\n")
+	for _, e := range d.elems {
+		bw.WriteString(e)
+		bw.WriteByte('\n')
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// candidates returns the runes possible in each cell after filling
+// grid, which may be nil, and propagating.
+func (p *Puzzle) candidates(grid [][]rune) ([]string, error) {
+	s, err := newSolver(p)
+	if err != nil {
+		return nil, err
+	}
+	if grid != nil {
+		if _, err := s.fill(grid); err != nil {
+			return nil, err
+		}
+	}
+	if !s.propagate(append([]*line(nil), s.lines...)) {
+		return nil, ErrNoSolution
+	}
+	candidates := make([]string, len(s.cellLines))
+	for i := range candidates {
+		var b strings.Builder
+		d := s.dom(i)
+		for j, r := range s.runes {
+			if d[j/64]&(1<<uint(j%64)) != 0 {
+				b.WriteRune(r)
+			}
+		}
+		candidates[i] = b.String()
+	}
+	return candidates, nil
+}
+
+// lineDirection returns the unit vector from each cell of a line on
+// the axis to the next.
+func lineDirection(axis Axis, hexagonal bool) (dx, dy float64) {
+	switch {
+	case axis == AxisY:
+		return 1, 0
+	case !hexagonal:
+		return 0, 1
+	case axis == AxisX:
+		return -0.5, math.Sqrt(3) / 2
+	default:
+		return -0.5, -math.Sqrt(3) / 2
+	}
+}
+
+// svgDrawing collects the elements of an image and their bounds.
+type svgDrawing struct {
+	elems                  []string
+	minX, minY, maxX, maxY float64
+	started                bool
+}
+
+func (d *svgDrawing) add(format string, args ...interface{}) {
+	d.elems = append(d.elems, fmt.Sprintf(format, args...))
+}
+
+func (d *svgDrawing) extend(x, y float64) {
+	if !d.started {
+		d.minX, d.minY, d.maxX, d.maxY = x, y, x, y
+		d.started = true
+		return
+	}
+	d.minX, d.maxX = math.Min(d.minX, x), math.Max(d.maxX, x)
+	d.minY, d.maxY = math.Min(d.minY, y), math.Max(d.maxY, y)
+}
+
+// clue writes text at x, y, running away from the line in the
+// direction dx, dy. Text that would run leftward is turned upright
+// and anchored at its end instead.
+func (d *svgDrawing) clue(x, y, dx, dy float64, text string, fontSize float64) {
+	if text == "" {
+		return
+	}
+	anchor := "start"
+	if dx < 0 || dx == 0 && dy > 0 {
+		dx, dy = -dx, -dy
+		anchor = "end"
+	}
+	angle := math.Atan2(dy, dx) * 180 / math.Pi
+	// Estimate the extent of the text for the bounds.
+	n := 0.6 * fontSize * float64(utf8.RuneCountInString(text))
+	if anchor == "end" {
+		n = -n
+	}
+	d.extend(x, y)
+	d.extend(x+dx*n, y+dy*n)
+	d.add(`<text x="%s" y="%s" font-size="%s" text-anchor="%s" transform="rotate(%s %s %s)">%s</text>`,
+		num(x), num(y), num(fontSize), anchor, num(angle), num(x), num(y), escape(text))
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package crossword
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// svgElem is an element of an SVG image, decoded loosely.
+type svgElem struct {
+	XMLName   xml.Name
+	Class     string `xml:"class,attr"`
+	Transform string `xml:"transform,attr"`
+	Text      string `xml:",chardata"`
+}
+
+func decodeSVG(t *testing.T, data []byte) []svgElem {
+	var svg struct {
+		Elems []svgElem `xml:",any"`
+	}
+	if err := xml.Unmarshal(data, &svg); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	return svg.Elems
+}
+
+func TestWriteSVG(t *testing.T) {
+	rect := Puzzle{
+		PatternsX: [][]string{{`[^SPEAK]+`, `EP|IP|EF`}},
+		PatternsY: [][]string{{`HE|LL|O+`, `[PLEASE]+`}},
+	}
+	var buf bytes.Buffer
+	if err := rect.WriteSVG(&buf, SVGOptions{Grid: runeGrid("H\x00", "\x00\x00"), Candidates: true}); err != nil {
+		t.Fatal(err)
+	}
+	count := make(map[string]int)
+	texts := make(map[string]svgElem)
+	for _, e := range decodeSVG(t, buf.Bytes()) {
+		count[e.XMLName.Local]++
+		if e.XMLName.Local == "text" {
+			texts[e.Text] = e
+		}
+	}
+	if count["rect"] != 4 || count["text"] != 8 {
+		t.Errorf("got %d rects and %d texts, want 4 and 8", count["rect"], count["text"])
+	}
+	if e := texts["H"]; e.Class != "cell" {
+		t.Errorf("filled cell: got class %q, want cell", e.Class)
+	}
+	for _, want := range []string{"E", "L", "P"} {
+		if e := texts[want]; e.Class != "candidates" {
+			t.Errorf("candidates %q: got class %q, want candidates", want, e.Class)
+		}
+	}
+	if e := texts["[^SPEAK]+"]; !strings.HasPrefix(e.Transform, "rotate(-90 ") {
+		t.Errorf("column clue: got transform %q, want rotate(-90 ...)", e.Transform)
+	}
+	if e := texts["HE|LL|O+"]; !strings.HasPrefix(e.Transform, "rotate(0 ") {
+		t.Errorf("row clue: got transform %q, want rotate(0 ...)", e.Transform)
+	}
+
+	buf.Reset()
+	if err := mitPuzzle.WriteSVG(&buf, SVGOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	angles := make(map[string]int)
+	polygons := 0
+	for _, e := range decodeSVG(t, buf.Bytes()) {
+		switch e.XMLName.Local {
+		case "polygon":
+			polygons++
+		case "text":
+			angles[strings.Fields(e.Transform)[0]]++
+		}
+	}
+	if polygons != 127 {
+		t.Errorf("got %d hexagons, want 127", polygons)
+	}
+	for _, angle := range []string{"rotate(0", "rotate(-60", "rotate(60"} {
+		if angles[angle] != 13 {
+			t.Errorf("got %d clues at %s), want 13", angles[angle], angle)
+		}
+	}
+
+	if err := rect.WriteSVG(&buf, SVGOptions{Grid: runeGrid("HE")}); err == nil {
+		t.Error("expected error for short grid")
+	}
+	if err := rect.WriteSVG(&buf, SVGOptions{Grid: runeGrid("HE", "LL"), Candidates: true}); err != ErrNoSolution {
+		t.Errorf("got error %v, want %v", err, ErrNoSolution)
+	}
+}
@@ -0,0 +1,193 @@
+package crossword
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParsePuzzle reads a puzzle in the text format written by WriteText.
+//
+// The format is a sequence of fields, one per line. A scalar field is
+// written as "key: value", and a list field as "key:" followed by its
+// items, one per indented line:
+//
+//	# Comments and blank lines are ignored.
+//	id: beginner-1
+//	name: Beatles
+//	hexagonal
+//	size: 7
+//	cellcharacters
+//	characters:
+//		ABC
+//	map: 0 2 1 3
+//	x:
+//		HE|LL|O+
+//		[PLEASE]+
+//	y:
+//		[^SPEAK]+
+//		EP|IP|EF
+//	solution:
+//		HE
+//		LP
+//
+// Each "x:", "y:" or "z:" list holds the patterns of one side of that
+// axis, so an axis clued from both sides has two lists. Characters
+// lists the strings of the puzzle's Characters, map its SolutionMap,
+// and solution the rows of its Solution. Hexagonal and cellcharacters
+// stand alone and set Hexagonal and CellCharacters.
+//
+// Values and items are trimmed of surrounding spaces. One that is
+// empty, that has surrounding spaces, that contains a line break, or
+// that begins with a double quote is written as a quoted Go string.
+func ParsePuzzle(r io.Reader) (*Puzzle, error) {
+	p := &Puzzle{}
+	var list *[]string // the list that indented items belong to
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if text[0] == ' ' || text[0] == '\t' {
+			if list == nil {
+				return nil, fmt.Errorf("crossword: line %d: item outside of a list", n)
+			}
+			item, err := unquoteText(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("crossword: line %d: %v", n, err)
+			}
+			*list = append(*list, item)
+			continue
+		}
+		list = nil
+		if trimmed == "hexagonal" {
+			p.Hexagonal = true
+			continue
+		}
+		if trimmed == "cellcharacters" {
+			p.CellCharacters = true
+			continue
+		}
+		i := strings.IndexByte(trimmed, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("crossword: line %d: want key: value", n)
+		}
+		key := trimmed[:i]
+		value, err := unquoteText(strings.TrimSpace(trimmed[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("crossword: line %d: %v", n, err)
+		}
+		isList := strings.TrimSpace(trimmed[i+1:]) == ""
+		switch key {
+		case "id":
+			p.ID = value
+		case "name":
+			p.Name = value
+		case "size":
+			if p.Size, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("crossword: line %d: bad size %q", n, value)
+			}
+		case "map":
+			for _, f := range strings.Fields(value) {
+				cell, err := strconv.Atoi(f)
+				if err != nil {
+					return nil, fmt.Errorf("crossword: line %d: bad cell %q in map", n, f)
+				}
+				p.SolutionMap = append(p.SolutionMap, cell)
+			}
+		case "characters", "solution", "x", "y", "z":
+			if !isList {
+				return nil, fmt.Errorf("crossword: line %d: %s is a list", n, key)
+			}
+			switch key {
+			case "characters":
+				list = &p.Characters
+			case "solution":
+				list = &p.Solution
+			default:
+				axis := map[string]*[][]string{"x": &p.PatternsX, "y": &p.PatternsY, "z": &p.PatternsZ}[key]
+				*axis = append(*axis, []string{})
+				list = &(*axis)[len(*axis)-1]
+			}
+		default:
+			return nil, fmt.Errorf("crossword: line %d: unknown key %q", n, key)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WriteText writes the puzzle in the text format read by ParsePuzzle.
+// Fields that are unset are omitted, as are the fields, such as dates
+// and ratings, that the site records about a puzzle.
+func (p *Puzzle) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if p.ID != "" {
+		fmt.Fprintf(bw, "id: %s\n", quoteText(p.ID))
+	}
+	if p.Name != "" {
+		fmt.Fprintf(bw, "name: %s\n", quoteText(p.Name))
+	}
+	if p.Hexagonal {
+		bw.WriteString("hexagonal\n")
+	}
+	if p.Size != 0 {
+		fmt.Fprintf(bw, "size: %d\n", p.Size)
+	}
+	if p.CellCharacters {
+		bw.WriteString("cellcharacters\n")
+	}
+	writeList(bw, "characters", p.Characters)
+	if len(p.SolutionMap) != 0 {
+		bw.WriteString("map:")
+		for _, cell := range p.SolutionMap {
+			fmt.Fprintf(bw, " %d", cell)
+		}
+		bw.WriteByte('\n')
+	}
+	for _, axis := range []struct {
+		name  string
+		sides [][]string
+	}{{"x", p.PatternsX}, {"y", p.PatternsY}, {"z", p.PatternsZ}} {
+		for _, side := range axis.sides {
+			fmt.Fprintf(bw, "%s:\n", axis.name)
+			writeItems(bw, side)
+		}
+	}
+	writeList(bw, "solution", p.Solution)
+	return bw.Flush()
+}
+
+func writeList(bw *bufio.Writer, key string, items []string) {
+	if len(items) != 0 {
+		fmt.Fprintf(bw, "%s:\n", key)
+		writeItems(bw, items)
+	}
+}
+
+func writeItems(bw *bufio.Writer, items []string) {
+	for _, item := range items {
+		fmt.Fprintf(bw, "\t%s\n", quoteText(item))
+	}
+}
+
+// quoteText quotes s if it would not otherwise read back unchanged.
+func quoteText(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || s[0] == '"' || strings.ContainsAny(s, "\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func unquoteText(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	return strconv.Unquote(s)
+}
@@ -0,0 +1,89 @@
+package crossword
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePuzzleMIT(t *testing.T) {
+	f, err := os.Open("testdata/mit.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := ParsePuzzle(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mitPuzzle
+	want.Name = "A Regular Crossword"
+	want.Solution = mitSolution
+	if !reflect.DeepEqual(p, &want) {
+		t.Errorf("got %+v, want %+v", p, &want)
+	}
+	if res := p.Check(runeGrid(p.Solution...)); !res.Solved {
+		t.Errorf("solution does not check: %+v", res.Failed())
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	for _, p := range []Puzzle{
+		mitPuzzle,
+		{
+			ID:          "odd",
+			Name:        " padded name ",
+			PatternsX:   [][]string{{`A`, ``}, {`"quoted"`, ` B`}},
+			PatternsY:   [][]string{{"#not a comment", "line\nbreak"}},
+			Characters:  []string{"A", "B", "", "\""},
+			SolutionMap: []int{3, 2, 1, 0},
+			Solution:    []string{"AB", "BA"},
+		},
+		{
+			ID:             "cells",
+			PatternsX:      [][]string{{`.`, `.`}},
+			PatternsY:      [][]string{{`AB|BA`}},
+			Characters:     []string{"B", "AB"},
+			CellCharacters: true,
+			Solution:       []string{"BA"},
+		},
+	} {
+		var buf bytes.Buffer
+		if err := p.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		text := buf.String()
+		q, err := ParsePuzzle(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", p.ID, err, text)
+		}
+		if !reflect.DeepEqual(q, &p) {
+			t.Errorf("%s: got %+v, want %+v\n%s", p.ID, q, &p, text)
+		}
+		buf.Reset()
+		if err := q.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != text {
+			t.Errorf("%s: rewritten as\n%s\nwant\n%s", p.ID, buf.String(), text)
+		}
+	}
+}
+
+func TestParsePuzzleErrors(t *testing.T) {
+	for _, text := range []string{
+		"\tA\n",
+		"size: seven\n",
+		"map: 1 x\n",
+		"x: A\n",
+		"color: red\n",
+		"id\n",
+		"x:\n\t\"unterminated\n",
+	} {
+		if _, err := ParsePuzzle(strings.NewReader(text)); err == nil {
+			t.Errorf("%q: expected error", text)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package regexp
 
 import (
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"unicode/utf8"
@@ -944,3 +945,224 @@ func TestMinInputLen(t *testing.T) {
 		}
 	}
 }
+
+var backrefTests = []struct {
+	pat   string
+	text  string
+	match []int
+}{
+	{`(a)\1`, "aa", []int{0, 2, 0, 1}},
+	{`(a)\1`, "ab", nil},
+	{`(.)\1`, "xyzzy", []int{2, 4, 2, 3}},
+	{`\1(a)`, "a", []int{0, 1, 0, 1}},
+	{`(?:(a)|b)\1c`, "bc", []int{0, 2, -1, -1}},
+	{`^(?:(...?)\1*)$`, "ABABAB", []int{0, 6, 0, 2}},
+	{`^(?:(...?)\1*)$`, "ABABA", nil},
+	{`.*(.)C\1X\1.*`, "ZACAXAY", []int{0, 7, 1, 2}},
+	{`(?P<x>日.)\k<x>`, "日本日本", []int{0, 12, 0, 6}},
+	{`(?<x>日.)\k<x>`, "日本日本", []int{0, 12, 0, 6}},
+	{`(?i)(a)\1`, "aA", []int{0, 2, 0, 1}},
+	{`(?i)(k)\1`, "k\u212a", []int{0, 4, 0, 1}},
+	{`(a)(?i:\1)`, "aA", []int{0, 2, 0, 1}},
+	{`(?i:(a))\1`, "aA", nil},
+	{`(a+)\1b`, "aaaab", []int{0, 5, 0, 2}},
+	{`^(a*)+b\1$`, "ab", nil},
+	{`^(a*)+b\1$`, "aab", nil},
+	{`(a*)+b\1`, "aab", []int{2, 3, 2, 2}},
+	{`^(a|)+\1b$`, "ab", nil},
+	{`(a|)+\1b`, "ab", []int{1, 2, 1, 1}},
+	{`^(a|)*?\1b$`, "aab", []int{0, 3, 0, 1}},
+}
+
+func TestBackref(t *testing.T) {
+	for _, tt := range backrefTests {
+		re, err := CompileFlags(tt.pat, syntax.Perl|syntax.Backref)
+		if err != nil {
+			t.Errorf("CompileFlags(%#q) = error %v", tt.pat, err)
+			continue
+		}
+		if m := re.FindStringSubmatchIndex(tt.text); !reflect.DeepEqual(m, tt.match) {
+			t.Errorf("%#q.FindStringSubmatchIndex(%q) = %v, want %v", tt.pat, tt.text, m, tt.match)
+		}
+		want := tt.match != nil
+		if m := re.MatchString(tt.text); m != want {
+			t.Errorf("%#q.MatchString(%q) = %v, want %v", tt.pat, tt.text, m, want)
+		}
+		if m := re.MatchReader(strings.NewReader(tt.text)); m != want {
+			t.Errorf("%#q.MatchReader(%q) = %v, want %v", tt.pat, tt.text, m, want)
+		}
+	}
+}
+
+func TestBackrefNeedsFlag(t *testing.T) {
+	if _, err := Compile(`(a)\1`); err == nil {
+		t.Errorf("Compile(%#q) succeeded without syntax.Backref", `(a)\1`)
+	}
+}
+
+var lookaroundTests = []struct {
+	pat   string
+	text  string
+	match []int
+}{
+	{`a(?=b)`, "acab", []int{2, 3}},
+	{`a(?!b)`, "abac", []int{2, 3}},
+	{`(?<=b)a`, "aba", []int{2, 3}},
+	{`(?<!b)a`, "baa", []int{2, 3}},
+	{`(?<=^|,)\w+`, "ab,cd", []int{0, 2}},
+	{`(?=(a+))a*b\1`, "baaabac", []int{3, 6, 3, 4}},
+	{`(?=(a+))`, "baaa", []int{1, 1, 1, 4}},
+	{`(?!(a))\1b`, "b", []int{0, 1, -1, -1}},
+	{`^(?=.*A)(?=.*B).{3}$`, "BXA", []int{0, 3}},
+	{`^(?=.*A)(?=.*B).{3}$`, "BXC", nil},
+	{`(?<=(\d)x)\1`, "1x1", []int{2, 3, 0, 1}},
+	{`(?<!a)(?<!b)c`, "acbcc", []int{4, 5}},
+	{`(?=(a|ab))`, "ab", []int{0, 0, 0, 1}},
+	{`(?=(|a))`, "a", []int{0, 0, 0, 0}},
+}
+
+func TestLookaround(t *testing.T) {
+	for _, tt := range lookaroundTests {
+		re, err := CompileFlags(tt.pat, syntax.Perl|syntax.Backref|syntax.Lookaround)
+		if err != nil {
+			t.Errorf("CompileFlags(%#q) = error %v", tt.pat, err)
+			continue
+		}
+		if m := re.FindStringSubmatchIndex(tt.text); !reflect.DeepEqual(m, tt.match) {
+			t.Errorf("%#q.FindStringSubmatchIndex(%q) = %v, want %v", tt.pat, tt.text, m, tt.match)
+		}
+		want := tt.match != nil
+		if m := re.MatchString(tt.text); m != want {
+			t.Errorf("%#q.MatchString(%q) = %v, want %v", tt.pat, tt.text, m, want)
+		}
+		if m := re.MatchReader(strings.NewReader(tt.text)); m != want {
+			t.Errorf("%#q.MatchReader(%q) = %v, want %v", tt.pat, tt.text, m, want)
+		}
+	}
+}
+
+func TestLookaroundNeedsFlag(t *testing.T) {
+	if _, err := Compile(`a(?=b)`); err == nil {
+		t.Errorf("Compile(%#q) succeeded without syntax.Lookaround", `a(?=b)`)
+	}
+}
+
+var atomicTests = []struct {
+	pat   string
+	text  string
+	match []int
+}{
+	{`(?>a+)b`, "aab", []int{0, 3}},
+	{`(?>a+)a`, "aaa", nil},
+	{`a*+a`, "aaa", nil},
+	{`a++b`, "aaab", []int{0, 4}},
+	{`a?+a`, "a", nil},
+	{`a?+a`, "aa", []int{0, 2}},
+	{`a{1,2}+a`, "aa", nil},
+	{`a{1,2}+a`, "aaa", []int{0, 3}},
+	{`(?>(a|xb|ab))c`, "abc", nil},
+	{`(?>(ab|xb|a))c`, "abc", []int{0, 3, 0, 2}},
+	{`(?>(a+))\1`, "aaaa", nil},
+	{`(?>(a)|b)*\1`, "aba", []int{0, 3, 0, 1}},
+	{`"(?>[^"]*+)"`, `x"yz"`, []int{1, 5}},
+	{`^(?>a|ab)$`, "ab", nil},
+	{`^(?>ab|a)$`, "ab", []int{0, 2}},
+	{`^(?>|a)a$`, "a", []int{0, 1}},
+	{`^(?>a?|b)b$`, "b", []int{0, 1}},
+	{`^(?:a|ab)++$`, "ab", nil},
+}
+
+func TestAtomic(t *testing.T) {
+	for _, tt := range atomicTests {
+		re, err := CompileFlags(tt.pat, syntax.Perl|syntax.Backref|syntax.Atomic)
+		if err != nil {
+			t.Errorf("CompileFlags(%#q) = error %v", tt.pat, err)
+			continue
+		}
+		if m := re.FindStringSubmatchIndex(tt.text); !reflect.DeepEqual(m, tt.match) {
+			t.Errorf("%#q.FindStringSubmatchIndex(%q) = %v, want %v", tt.pat, tt.text, m, tt.match)
+		}
+		want := tt.match != nil
+		if m := re.MatchString(tt.text); m != want {
+			t.Errorf("%#q.MatchString(%q) = %v, want %v", tt.pat, tt.text, m, want)
+		}
+		if m := re.MatchReader(strings.NewReader(tt.text)); m != want {
+			t.Errorf("%#q.MatchReader(%q) = %v, want %v", tt.pat, tt.text, m, want)
+		}
+	}
+}
+
+// TestEnumerateMatches checks that syntax.Enumerate lists exactly the
+// short strings that the backtracker matches in full, for the patterns
+// of the backreference, lookaround and atomic tests, over the runes of
+// their texts.
+func TestEnumerateMatches(t *testing.T) {
+	const flags = syntax.Perl | syntax.Backref | syntax.Lookaround | syntax.Atomic
+	var pats, texts []string
+	for _, tt := range backrefTests {
+		pats, texts = append(pats, tt.pat), append(texts, tt.text)
+	}
+	for _, tt := range lookaroundTests {
+		pats, texts = append(pats, tt.pat), append(texts, tt.text)
+	}
+	for _, tt := range atomicTests {
+		pats, texts = append(pats, tt.pat), append(texts, tt.text)
+	}
+	for i, pat := range pats {
+		var alphabet []rune
+		for _, r := range texts[i] {
+			if !strings.ContainsRune(string(alphabet), r) {
+				alphabet = append(alphabet, r)
+			}
+		}
+		re, err := syntax.Parse(pat, flags)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", pat, err)
+			continue
+		}
+		full := MustCompileFlags(`^(?:`+pat+`)$`, flags)
+		for n := 0; n <= 4; n++ {
+			var got []string
+			syntax.Enumerate(re, n, alphabet, func(s string) bool {
+				got = append(got, s)
+				return true
+			})
+			var want []string
+			forEachString(alphabet, n, func(s string) {
+				if full.MatchString(s) {
+					want = append(want, s)
+				}
+			})
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Enumerate(%#q, %d, %q) = %q, want %q", pat, n, string(alphabet), got, want)
+			}
+		}
+	}
+}
+
+// forEachString calls f with each string of n runes from alphabet.
+func forEachString(alphabet []rune, n int, f func(string)) {
+	buf := make([]rune, n)
+	var walk func(i int)
+	walk = func(i int) {
+		if i == n {
+			f(string(buf))
+			return
+		}
+		for _, r := range alphabet {
+			buf[i] = r
+			walk(i + 1)
+		}
+	}
+	walk(0)
+}
+
+func TestAtomicNeedsFlag(t *testing.T) {
+	for _, pat := range []string{`(?>a)`, `a*+`} {
+		if _, err := Compile(pat); err == nil {
+			t.Errorf("Compile(%#q) succeeded without syntax.Atomic", pat)
+		}
+	}
+}
@@ -11,11 +11,20 @@
 //
 // backtrack is a fast replacement for the NFA code on small
 // regexps when onepass cannot be used.
+//
+// backtrack is also the only matcher that executes backreferences.
+// Whether a (character position, instruction) state can lead to a
+// match then also depends on the capture registers, so the visited
+// states are keyed by all three, and the search is no longer linear.
+// Lookaround assertions and atomic groups are run the same way, each
+// by a search of its own from the position where it starts.
 
 package regexp
 
 import (
+	"strconv"
 	"sync"
+	"unicode"
 
 	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
 )
@@ -38,9 +47,19 @@ const (
 type bitState struct {
 	end      int
 	cap      []int
+	loop     []int // starts of the iterations that InstProgress checks
 	matchcap []int
 	jobs     []job
 	visited  []uint32
+	seen     map[string]bool // visited states with captures, for backreferences
+	key      []byte
+	saved    [][]int // capture registers from before positive lookarounds and atomic groups
+
+	// A search for the subexpression of a lookaround or atomic group
+	// takes the first match that ends at lookEnd, or anywhere if
+	// lookEnd is -1, and sets lookEnd to where it ended.
+	look    bool
+	lookEnd int
 
 	inputs inputs
 }
@@ -78,8 +97,10 @@ func shouldBacktrack(prog *syntax.Prog) bool {
 // reset resets the state of the backtracker.
 // end is the end position in the input.
 // ncap is the number of captures.
-func (b *bitState) reset(prog *syntax.Prog, end int, ncap int) {
+func (b *bitState) reset(prog *syntax.Prog, end int, ncap int, backref bool) {
 	b.end = end
+	b.saved = b.saved[:0]
+	b.look = false
 
 	if cap(b.jobs) == 0 {
 		b.jobs = make([]job, 0, 256)
@@ -87,8 +108,17 @@ func (b *bitState) reset(prog *syntax.Prog, end int, ncap int) {
 		b.jobs = b.jobs[:0]
 	}
 
+	if backref {
+		b.visited = b.visited[:0]
+		b.seen = make(map[string]bool)
+	} else {
+		b.seen = nil
+	}
+
 	visitedSize := (len(prog.Inst)*(end+1) + visitedBits - 1) / visitedBits
-	if cap(b.visited) < visitedSize {
+	if backref {
+		// Visited states are recorded in seen instead.
+	} else if cap(b.visited) < visitedSize {
 		b.visited = make([]uint32, visitedSize, maxBacktrackVector/visitedBits)
 	} else {
 		b.visited = b.visited[:visitedSize]
@@ -106,6 +136,15 @@ func (b *bitState) reset(prog *syntax.Prog, end int, ncap int) {
 		b.cap[i] = -1
 	}
 
+	if cap(b.loop) < prog.NumLoop {
+		b.loop = make([]int, prog.NumLoop)
+	} else {
+		b.loop = b.loop[:prog.NumLoop]
+	}
+	for i := range b.loop {
+		b.loop[i] = -1
+	}
+
 	if cap(b.matchcap) < ncap {
 		b.matchcap = make([]int, ncap)
 	} else {
@@ -119,6 +158,9 @@ func (b *bitState) reset(prog *syntax.Prog, end int, ncap int) {
 // shouldVisit reports whether the combination of (pc, pos) has not
 // been visited yet.
 func (b *bitState) shouldVisit(pc uint32, pos int) bool {
+	if b.seen != nil {
+		return b.shouldVisitCaps(pc, pos)
+	}
 	n := uint(int(pc)*(b.end+1) + pos)
 	if b.visited[n/visitedBits]&(1<<(n&(visitedBits-1))) != 0 {
 		return false
@@ -127,6 +169,27 @@ func (b *bitState) shouldVisit(pc uint32, pos int) bool {
 	return true
 }
 
+// shouldVisitCaps reports whether the combination of (pc, pos) and
+// the current capture and loop registers has not been visited yet.
+func (b *bitState) shouldVisitCaps(pc uint32, pos int) bool {
+	b.key = strconv.AppendUint(b.key[:0], uint64(pc), 36)
+	b.key = append(b.key, ',')
+	b.key = strconv.AppendInt(b.key, int64(pos), 36)
+	for _, c := range b.cap {
+		b.key = append(b.key, ',')
+		b.key = strconv.AppendInt(b.key, int64(c), 36)
+	}
+	for _, l := range b.loop {
+		b.key = append(b.key, ';')
+		b.key = strconv.AppendInt(b.key, int64(l), 36)
+	}
+	if b.seen[string(b.key)] {
+		return false
+	}
+	b.seen[string(b.key)] = true
+	return true
+}
+
 // push pushes (pc, pos, arg) onto the job stack if it should be
 // visited.
 func (b *bitState) push(re *Regexp, pc uint32, pos int, arg bool) {
@@ -257,6 +320,85 @@ func (re *Regexp) tryBacktrack(b *bitState, i input, pc uint32, pos int) bool {
 				goto CheckAndLoop
 			}
 
+		case syntax.InstLoop:
+			k := inst.Arg >> 1
+			if arg {
+				// Finished inst.Out; restore the old value.
+				b.loop[k] = pos
+				continue
+			}
+			// Record where the iteration starts, but save the old
+			// value. The first iteration of x+ may match empty.
+			b.push(re, pc, b.loop[k], true)
+			b.loop[k] = pos
+			if inst.Arg&1 != 0 {
+				b.loop[k] = -1
+			}
+			pc = inst.Out
+			goto CheckAndLoop
+
+		case syntax.InstProgress:
+			// As in JavaScript, an iteration past the minimum
+			// must consume input.
+			if b.loop[inst.Arg] == pos {
+				continue
+			}
+			pc = inst.Out
+			goto CheckAndLoop
+
+		case syntax.InstBackref:
+			// A reference to a group that has not matched
+			// matches the empty string.
+			n, fold := int(inst.Arg>>1), inst.Arg&1 != 0
+			lo, hi := -1, -1
+			if 2*n+1 < len(b.cap) {
+				lo, hi = b.cap[2*n], b.cap[2*n+1]
+			}
+			for lo >= 0 && lo < hi {
+				r1, w1 := i.step(lo)
+				r2, w2 := i.step(pos)
+				if w2 == 0 || r1 != r2 && !(fold && equalFold(r1, r2)) {
+					break
+				}
+				lo += w1
+				pos += w2
+			}
+			if lo >= 0 && lo < hi {
+				continue
+			}
+			pc = inst.Out
+			goto CheckAndLoop
+
+		case syntax.InstLookahead, syntax.InstNegLookahead,
+			syntax.InstLookbehind, syntax.InstNegLookbehind,
+			syntax.InstAtomic:
+			if arg {
+				// Backtracking past a positive lookaround or an
+				// atomic group; restore the captures from before it.
+				copy(b.cap, b.saved[pos])
+				b.saved = b.saved[:pos]
+				continue
+			}
+			caps, end := re.look(b, i, inst, pos)
+			switch inst.Op {
+			case syntax.InstLookahead, syntax.InstLookbehind, syntax.InstAtomic:
+				if caps == nil {
+					continue
+				}
+				b.push(re, pc, len(b.saved), true)
+				b.saved = append(b.saved, append([]int(nil), b.cap...))
+				copy(b.cap, caps)
+				if inst.Op == syntax.InstAtomic {
+					pos = end
+				}
+			default:
+				if caps != nil {
+					continue
+				}
+			}
+			pc = inst.Out
+			goto CheckAndLoop
+
 		case syntax.InstEmptyWidth:
 			flag := i.context(pos)
 			if !flag.match(syntax.EmptyOp(inst.Arg)) {
@@ -270,6 +412,15 @@ func (re *Regexp) tryBacktrack(b *bitState, i input, pc uint32, pos int) bool {
 			goto CheckAndLoop
 
 		case syntax.InstMatch:
+			if b.look {
+				if b.lookEnd >= 0 && pos != b.lookEnd {
+					continue
+				}
+				b.lookEnd = pos
+				copy(b.matchcap, b.cap)
+				return true
+			}
+
 			// We found a match. If the caller doesn't care
 			// where the match is, no point going further.
 			if len(b.cap) == 0 {
@@ -304,6 +455,35 @@ func (re *Regexp) tryBacktrack(b *bitState, i input, pc uint32, pos int) bool {
 	return longest && len(b.matchcap) > 1 && b.matchcap[1] >= 0
 }
 
+// look runs the subexpression of the lookaround or atomic group inst
+// at pos and returns the capture registers and end of its first match,
+// or nil if it does not match. A lookahead or atomic group matches
+// from pos and a lookbehind matches up to pos from the earliest
+// position that it can.
+func (re *Regexp) look(b *bitState, i input, inst syntax.Inst, pos int) ([]int, int) {
+	lb := newBitState()
+	defer freeBitState(lb)
+	lb.reset(re.prog, b.end, len(b.cap), true)
+	lb.look = true
+	lb.lookEnd = -1
+	start := pos
+	if inst.Op == syntax.InstLookbehind || inst.Op == syntax.InstNegLookbehind {
+		lb.lookEnd = pos
+		start = 0
+	}
+	for {
+		copy(lb.cap, b.cap)
+		if re.tryBacktrack(lb, i, inst.Arg, start) {
+			return append([]int(nil), lb.matchcap...), lb.lookEnd
+		}
+		if start == pos {
+			return nil, -1
+		}
+		_, width := i.step(start)
+		start += width
+	}
+}
+
 // backtrack runs a backtracking search of prog on the input starting at pos.
 func (re *Regexp) backtrack(ib []byte, is string, pos int, ncap int, dstCap []int) []int {
 	startCond := re.cond
@@ -315,9 +495,16 @@ func (re *Regexp) backtrack(ib []byte, is string, pos int, ncap int, dstCap []in
 		return nil
 	}
 
+	// Backreferences read the capture registers, so they must be
+	// recorded even if the caller does not want them.
+	bcap := ncap
+	if re.backref && bcap < re.prog.NumCap {
+		bcap = re.prog.NumCap
+	}
+
 	b := newBitState()
 	i, end := b.inputs.init(nil, ib, is)
-	b.reset(re.prog, end, ncap)
+	b.reset(re.prog, end, bcap, re.backref)
 
 	// Anchored search must start at the beginning of the input
 	if startCond&syntax.EmptyBeginText != 0 {
@@ -362,7 +549,18 @@ func (re *Regexp) backtrack(ib []byte, is string, pos int, ncap int, dstCap []in
 	}
 
 Match:
-	dstCap = append(dstCap, b.matchcap...)
+	dstCap = append(dstCap, b.matchcap[:ncap]...)
 	freeBitState(b)
 	return dstCap
 }
+
+// equalFold reports whether r1 and r2 are equal under simple Unicode
+// case folding.
+func equalFold(r1, r2 rune) bool {
+	for f := unicode.SimpleFold(r1); f != r1; f = unicode.SimpleFold(f) {
+		if f == r2 {
+			return true
+		}
+	}
+	return r1 == r2
+}
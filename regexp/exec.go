@@ -6,6 +6,7 @@ package regexp
 
 import (
 	"io"
+	"strings"
 	"sync"
 
 	"github.com/andrewarchi/regexp-crossword/regexp/syntax"
@@ -532,6 +533,18 @@ func (re *Regexp) doExecute(r io.RuneReader, b []byte, s string, pos int, ncap i
 	if re.onepass != nil {
 		return re.doOnePass(r, b, s, pos, ncap, dstCap)
 	}
+	if r != nil && re.backref {
+		// The backtracker needs random access to the input.
+		var buf strings.Builder
+		for {
+			c, _, err := r.ReadRune()
+			if err != nil {
+				break
+			}
+			buf.WriteRune(c)
+		}
+		r, s = nil, buf.String()
+	}
 	if r == nil && len(b)+len(s) < re.maxBitStateLen {
 		return re.backtrack(b, s, pos, ncap, dstCap)
 	}
@@ -59,7 +59,8 @@
 // This set may grow. Note that regular expression matches may need to
 // examine text beyond the text returned by a match, so the methods that
 // match text from a RuneReader may read arbitrarily far into the input
-// before returning.
+// before returning. For regexps with backreferences, lookarounds or
+// atomic groups, they read the whole input into memory first.
 //
 // (There are a few other methods that do not match this pattern.)
 //
@@ -68,6 +69,7 @@ package regexp
 import (
 	"bytes"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -96,6 +98,7 @@ type Regexp struct {
 	prefixComplete bool           // prefix is the entire regexp
 	cond           syntax.EmptyOp // empty-width conditions required at start of match
 	minInputLen    int            // minimum length of the input in bytes
+	backref        bool           // prog contains backreferences, lookarounds or atomic groups
 
 	// This field can be modified by the Longest method,
 	// but it is otherwise read-only.
@@ -157,6 +160,25 @@ func CompilePOSIX(expr string) (*Regexp, error) {
 	return compile(expr, syntax.POSIX, true)
 }
 
+// CompileFlags is like Compile but parses the regular expression with
+// the given syntax flags. With syntax.Backref, the expression may
+// contain backreferences, as in JavaScript: \1 through \9 and \k<name>,
+// and groups may be named with (?<name>re) as well as (?P<name>re).
+// A backreference to a group that has not matched matches the empty
+// string, and one under (?i) matches the group's text in any case.
+// With syntax.Lookaround, it may contain the lookahead and
+// lookbehind assertions (?=re), (?!re), (?<=re) and (?<!re), which,
+// as in JavaScript, are atomic and keep the groups that a positive
+// assertion captured. With syntax.Atomic, it may contain the atomic
+// groups (?>re) and the possessive repetitions x*+, x++, x?+ and
+// x{n,m}+, which keep the first match of their subexpression.
+// Regexps with backreferences, lookarounds or atomic groups are always
+// executed by backtracking, so, unlike other regexps, they are not
+// guaranteed to run in time linear in the size of the input.
+func CompileFlags(expr string, flags syntax.Flags) (*Regexp, error) {
+	return compile(expr, flags, false)
+}
+
 // Longest makes future searches prefer the leftmost-longest match.
 // That is, when matching against text, the regexp returns a match that
 // begins as early as possible in the input (leftmost), and among those
@@ -187,17 +209,25 @@ func compile(expr string, mode syntax.Flags, longest bool) (*Regexp, error) {
 	regexp := &Regexp{
 		expr:        expr,
 		prog:        prog,
-		onepass:     compileOnePass(prog),
 		numSubexp:   maxCap,
 		subexpNames: capNames,
 		cond:        prog.StartCond(),
 		longest:     longest,
 		matchcap:    matchcap,
 		minInputLen: minInputLen(re),
+		backref:     re.NeedsBacktrack(),
+	}
+	if !regexp.backref {
+		regexp.onepass = compileOnePass(prog)
 	}
 	if regexp.onepass == nil {
 		regexp.prefix, regexp.prefixComplete = prog.Prefix()
 		regexp.maxBitStateLen = maxBitStateLen(prog)
+		if regexp.backref {
+			// Only the backtracker executes backreferences,
+			// lookarounds and atomic groups.
+			regexp.maxBitStateLen = math.MaxInt32
+		}
 	} else {
 		regexp.prefix, regexp.prefixComplete, regexp.prefixEnd = onePassPrefix(prog)
 	}
@@ -314,6 +344,17 @@ func MustCompile(str string) *Regexp {
 	return regexp
 }
 
+// MustCompileFlags is like CompileFlags but panics if the expression cannot be parsed.
+// It simplifies safe initialization of global variables holding compiled regular
+// expressions.
+func MustCompileFlags(str string, flags syntax.Flags) *Regexp {
+	regexp, err := CompileFlags(str, flags)
+	if err != nil {
+		panic(`regexp: CompileFlags(` + quote(str) + `): ` + err.Error())
+	}
+	return regexp
+}
+
 // MustCompilePOSIX is like CompilePOSIX but panics if the expression cannot be parsed.
 // It simplifies safe initialization of global variables holding compiled regular
 // expressions.
@@ -514,7 +555,9 @@ func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
 }
 
 // MatchReader reports whether the text returned by the RuneReader
-// contains any match of the regular expression re.
+// contains any match of the regular expression re. If re has
+// backreferences, lookarounds or atomic groups, it reads all of the
+// text into memory first.
 func (re *Regexp) MatchReader(r io.RuneReader) bool {
 	return re.doMatch(r, nil, "")
 }
@@ -864,6 +907,8 @@ func (re *Regexp) FindStringIndex(s string) (loc []int) {
 // the RuneReader. The match text was found in the input stream at
 // byte offset loc[0] through loc[1]-1.
 // A return value of nil indicates no match.
+// As with MatchReader, a regexp with backreferences, lookarounds or
+// atomic groups reads all of the text into memory first.
 func (re *Regexp) FindReaderIndex(r io.RuneReader) (loc []int) {
 	a := re.doExecute(r, nil, "", 0, 2, nil)
 	if a == nil {
@@ -1063,6 +1108,8 @@ func (re *Regexp) FindStringSubmatchIndex(s string) []int {
 // the RuneReader, and the matches, if any, of its subexpressions, as defined
 // by the 'Submatch' and 'Index' descriptions in the package comment. A
 // return value of nil indicates no match.
+// As with MatchReader, a regexp with backreferences, lookarounds or
+// atomic groups reads all of the text into memory first.
 func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
 	return re.pad(re.doExecute(r, nil, "", 0, re.prog.NumCap, nil))
 }
@@ -72,14 +72,21 @@ type frag struct {
 }
 
 type compiler struct {
-	p *Prog
+	p        *Prog
+	progress bool // whether repetitions that may match empty check their iterations
 }
 
 // Compile compiles the regexp into a program to be executed.
 // The regexp should have been simplified already (returned from re.Simplify).
+// Backreferences compile to InstBackref, lookaround assertions to
+// InstLookahead and its kin, and atomic groups to InstAtomic, which
+// only a backtracking matcher can execute. In such programs, as in
+// JavaScript, an iteration of a repetition past its minimum fails if
+// it matches the empty string, which InstLoop and InstProgress check.
 func Compile(re *Regexp) (*Prog, error) {
 	var c compiler
 	c.init()
+	c.progress = re.NeedsBacktrack()
 	f := c.compile(re)
 	f.out.patch(c.p, c.inst(InstMatch).i)
 	c.p.Start = int(f.i)
@@ -139,11 +146,11 @@ func (c *compiler) compile(re *Regexp) frag {
 		ket := c.cap(uint32(re.Cap<<1 | 1))
 		return c.cat(c.cat(bra, sub), ket)
 	case OpStar:
-		return c.star(c.compile(re.Sub[0]), re.Flags&NonGreedy != 0)
+		return c.star(c.compile(re.Sub[0]), re.Flags&NonGreedy != 0, c.progress && matchesEmpty(re.Sub[0]))
 	case OpPlus:
-		return c.plus(c.compile(re.Sub[0]), re.Flags&NonGreedy != 0)
+		return c.plus(c.compile(re.Sub[0]), re.Flags&NonGreedy != 0, c.progress && matchesEmpty(re.Sub[0]))
 	case OpQuest:
-		return c.quest(c.compile(re.Sub[0]), re.Flags&NonGreedy != 0)
+		return c.quest(c.compile(re.Sub[0]), re.Flags&NonGreedy != 0, c.progress && matchesEmpty(re.Sub[0]))
 	case OpConcat:
 		if len(re.Sub) == 0 {
 			return c.nop()
@@ -164,7 +171,9 @@ func (c *compiler) compile(re *Regexp) frag {
 		}
 		return f
 	case OpBackref:
-		panic("regexp: non-expanded backreference in compile")
+		return c.backref(uint32(re.Cap), re.Flags)
+	case OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind, OpAtomic:
+		return c.subprogram(re.Op, c.compile(re.Sub[0]))
 	}
 	panic("regexp: unhandled case in compile")
 }
@@ -197,6 +206,54 @@ func (c *compiler) cap(arg uint32) frag {
 	return f
 }
 
+// backref compiles a reference to capture n. The capture registers
+// that it reads are 2n and 2n+1. The argument holds n shifted left by
+// one, with the low bit set if the reference ignores case.
+func (c *compiler) backref(n uint32, flags Flags) frag {
+	f := c.inst(InstBackref)
+	f.out = patchList(f.i << 1)
+	c.p.Inst[f.i].Arg = n << 1
+	if flags&FoldCase != 0 {
+		c.p.Inst[f.i].Arg |= 1
+	}
+
+	if c.p.NumCap < int(2*n+2) {
+		c.p.NumCap = int(2*n + 2)
+	}
+	return f
+}
+
+// subprogram compiles a lookaround assertion or atomic group of the
+// fragment f1. The instruction continues at Out and runs f1 as a
+// separate program from Arg, which ends in its own InstMatch.
+func (c *compiler) subprogram(op Op, f1 frag) frag {
+	negate := op == OpNegLookahead || op == OpNegLookbehind
+	if f1.i == 0 {
+		// assertion on failure always fails or always holds
+		if negate {
+			return c.nop()
+		}
+		return c.fail()
+	}
+	f1.out.patch(c.p, c.inst(InstMatch).i)
+	var f frag
+	switch op {
+	case OpLookahead:
+		f = c.inst(InstLookahead)
+	case OpNegLookahead:
+		f = c.inst(InstNegLookahead)
+	case OpLookbehind:
+		f = c.inst(InstLookbehind)
+	case OpNegLookbehind:
+		f = c.inst(InstNegLookbehind)
+	case OpAtomic:
+		f = c.inst(InstAtomic)
+	}
+	c.p.Inst[f.i].Arg = f1.i
+	f.out = patchList(f.i << 1)
+	return f
+}
+
 func (c *compiler) cat(f1, f2 frag) frag {
 	// concat of failure is failure
 	if f1.i == 0 || f2.i == 0 {
@@ -226,7 +283,11 @@ func (c *compiler) alt(f1, f2 frag) frag {
 	return f
 }
 
-func (c *compiler) quest(f1 frag, nongreedy bool) frag {
+func (c *compiler) quest(f1 frag, nongreedy, progress bool) frag {
+	if progress && f1.i != 0 {
+		k := c.loopReg()
+		f1 = c.cat(c.loop(k, false), c.cat(f1, c.progressCheck(k)))
+	}
 	f := c.inst(InstAlt)
 	i := &c.p.Inst[f.i]
 	if nongreedy {
@@ -240,7 +301,29 @@ func (c *compiler) quest(f1 frag, nongreedy bool) frag {
 	return f
 }
 
-func (c *compiler) star(f1 frag, nongreedy bool) frag {
+func (c *compiler) star(f1 frag, nongreedy, progress bool) frag {
+	if progress && f1.i != 0 {
+		k := c.loopReg()
+		f1 = c.cat(c.loop(k, false), c.cat(f1, c.progressCheck(k)))
+	}
+	return c.repeat(f1, nongreedy)
+}
+
+func (c *compiler) plus(f1 frag, nongreedy, progress bool) frag {
+	if progress && f1.i != 0 {
+		// The first iteration may match the empty string and
+		// the others share its instructions, so it unsets the
+		// loop register instead.
+		k := c.loopReg()
+		body := c.cat(f1, c.progressCheck(k))
+		first := c.cat(c.loop(k, true), body)
+		return frag{first.i, c.repeat(c.cat(c.loop(k, false), body), nongreedy).out}
+	}
+	return frag{f1.i, c.repeat(f1, nongreedy).out}
+}
+
+// repeat compiles a loop that runs f1 zero or more times.
+func (c *compiler) repeat(f1 frag, nongreedy bool) frag {
 	f := c.inst(InstAlt)
 	i := &c.p.Inst[f.i]
 	if nongreedy {
@@ -254,8 +337,33 @@ func (c *compiler) star(f1 frag, nongreedy bool) frag {
 	return f
 }
 
-func (c *compiler) plus(f1 frag, nongreedy bool) frag {
-	return frag{f1.i, c.star(f1, nongreedy).out}
+// loopReg allocates a loop register.
+func (c *compiler) loopReg() uint32 {
+	c.p.NumLoop++
+	return uint32(c.p.NumLoop - 1)
+}
+
+// loop compiles the start of an iteration, which records the
+// position in loop register k, or unsets it if first is set. The
+// argument holds k shifted left by one, with the low bit set for
+// first.
+func (c *compiler) loop(k uint32, first bool) frag {
+	f := c.inst(InstLoop)
+	f.out = patchList(f.i << 1)
+	c.p.Inst[f.i].Arg = k << 1
+	if first {
+		c.p.Inst[f.i].Arg |= 1
+	}
+	return f
+}
+
+// progressCheck compiles the end of an iteration, which fails if the
+// position is the one recorded in loop register k.
+func (c *compiler) progressCheck(k uint32) frag {
+	f := c.inst(InstProgress)
+	f.out = patchList(f.i << 1)
+	c.p.Inst[f.i].Arg = k
+	return f
 }
 
 func (c *compiler) empty(op EmptyOp) frag {
@@ -289,3 +397,32 @@ func (c *compiler) rune(r []rune, flags Flags) frag {
 
 	return f
 }
+
+// matchesEmpty reports whether re may match the empty string.
+func matchesEmpty(re *Regexp) bool {
+	switch re.Op {
+	case OpNoMatch, OpCharClass, OpAnyCharNotNL, OpAnyChar:
+		return false
+	case OpLiteral:
+		return len(re.Rune) == 0
+	case OpCapture, OpPlus, OpAtomic:
+		return matchesEmpty(re.Sub[0])
+	case OpRepeat:
+		return re.Min == 0 || matchesEmpty(re.Sub[0])
+	case OpConcat:
+		for _, sub := range re.Sub {
+			if !matchesEmpty(sub) {
+				return false
+			}
+		}
+		return true
+	case OpAlternate:
+		for _, sub := range re.Sub {
+			if matchesEmpty(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
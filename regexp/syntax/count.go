@@ -0,0 +1,117 @@
+package syntax
+
+import (
+	"math/big"
+	"sort"
+)
+
+// maxExactCount is the largest bound on the count of a regexp with
+// backreferences, lookarounds or atomic groups for which Count
+// enumerates the strings to count them exactly.
+const maxExactCount = 1 << 16
+
+// Count returns the number of strings of length n over alphabet that
+// re matches in full, and whether the count is exact. Only for a
+// regexp with backreferences, lookarounds or atomic groups, and more
+// than 65536 strings that its approximation matches, is it not: then
+// the count is an upper bound.
+//
+// The program of the length-constrained form of re is run over every
+// string at once, a rune at a time, counting together the strings
+// that leave the program in the same states and the runes that the
+// program does not tell apart.
+//
+// Backreferences are not regular, so for a regexp with them the
+// program is made from the approximation of re that IsEmpty and Subset
+// explore, which matches every string that re does. The count of that
+// program is an upper bound, and if it is at most 65536, Count
+// enumerates the strings to count them exactly.
+func Count(re *Regexp, n int, alphabet []rune) (count *big.Int, exact bool) {
+	if n < 0 {
+		return new(big.Int), true
+	}
+	runes, class := alphabetClass(alphabet)
+	re = re.Simplify()
+	if !re.NeedsBacktrack() {
+		return countProg(re.Mask(class), n, runes), true
+	}
+	bound := countProg(approxBackrefs(re).Mask(class), n, runes)
+	if bound.Sign() == 0 {
+		return bound, true
+	}
+	if bound.Cmp(big.NewInt(maxExactCount)) > 0 {
+		return bound, false
+	}
+	var c int64
+	Enumerate(re, n, runes, func(string) bool {
+		c++
+		return true
+	})
+	return big.NewInt(c), true
+}
+
+// countProg counts the strings of length n over runes that re, which
+// has no backreferences, matches.
+func countProg(re *Regexp, n int, runes []rune) *big.Int {
+	total := new(big.Int)
+	sized, err := ConstrainLength(re, n, n+1)
+	if err != nil {
+		return total
+	}
+	prog, err := Compile(sized.Size(n))
+	if err != nil {
+		return total
+	}
+
+	// Runes in the same range of the partition are interchangeable,
+	// so each range is stepped once, weighted by its runes.
+	reps := partition([]*Prog{prog})
+	var group []rune
+	var weight []int64
+	for _, r := range runes {
+		i := sort.Search(len(reps), func(i int) bool { return reps[i] > r }) - 1
+		if len(group) != 0 && reps[i] <= group[len(group)-1] {
+			weight[len(weight)-1]++
+			continue
+		}
+		group = append(group, r)
+		weight = append(weight, 1)
+	}
+
+	type state struct {
+		pcs    []uint32
+		before rune
+		count  *big.Int
+	}
+	start := &state{[]uint32{uint32(prog.Start)}, -1, big.NewInt(1)}
+	layer := map[string]*state{"": start}
+	for i := 0; i < n; i++ {
+		next := make(map[string]*state)
+		for _, s := range layer {
+			for j, r := range group {
+				pcs := step(prog, closure(prog, s.pcs, s.before, r), r)
+				if len(pcs) == 0 {
+					continue
+				}
+				before := contextRune(r)
+				key := stateKey([][]uint32{pcs}, before)
+				t, ok := next[key]
+				if !ok {
+					t = &state{pcs, before, new(big.Int)}
+					next[key] = t
+				}
+				t.count.Add(t.count, new(big.Int).Mul(s.count, big.NewInt(weight[j])))
+			}
+		}
+		layer = next
+	}
+	for _, s := range layer {
+		for _, pc := range closure(prog, s.pcs, s.before, -1) {
+			if prog.Inst[pc].Op == InstMatch {
+				total.Add(total, s.count)
+				break
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,90 @@
+package syntax
+
+import (
+	"math/big"
+	"testing"
+)
+
+var countTests = []struct {
+	Regexp   string
+	Length   int
+	Alphabet string
+	Count    int64
+	Exact    bool
+}{
+	{`.*`, 3, "ABCDEFGHIJKLMNOPQRSTUVWXYZ", 17576, true},
+	{`[AB]*`, 3, "ABC", 8, true},
+	{`A*B?C*`, 3, "ABC", 7, true},
+	{`(A|AA)*`, 4, "A", 1, true},
+	{`HE|LL|O+`, 2, "EHLOP", 3, true},
+	{`[^A]`, 1, "ABC", 2, true},
+	{`\bA..`, 3, "A -", 9, true},
+	{`A*`, -1, "A", 0, true},
+	{`(.)\1`, 2, "ABC", 3, true},
+	{`(.)(?i)\1`, 2, "ABab", 8, true},
+	{`(...?)\1*`, 4, "AB", 4, true},
+	{`(?:(A)|B)\1C`, 2, "ABC", 1, true},
+	{`(?=.*A).*`, 3, "AB", 7, true},
+	{`[AB]*+B`, 3, "AB", 0, true},
+	{`(A)\1`, 3, "AB", 0, true},
+	{`(.)\1.*`, 5, "ABCDEFGHIJKLMNOPQRSTUVWXYZ", 11881376, false},
+}
+
+func TestCount(t *testing.T) {
+	for _, tt := range countTests {
+		re, err := Parse(tt.Regexp, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		if c, exact := Count(re, tt.Length, []rune(tt.Alphabet)); c.Cmp(big.NewInt(tt.Count)) != 0 || exact != tt.Exact {
+			t.Errorf("Count(%#q, %d, %q) = %v, %v, want %d, %v", tt.Regexp, tt.Length, tt.Alphabet, c, exact, tt.Count, tt.Exact)
+		}
+	}
+}
+
+// TestCountEnumerate checks Count against the strings that Enumerate
+// lists.
+func TestCountEnumerate(t *testing.T) {
+	alphabet := []rune("AB\n_")
+	for _, expr := range []string{
+		`.*`,
+		`(?s).*`,
+		`(A|B)*B(A|B)`,
+		`(?m)[AB]*$\n^.*`,
+		`.*\b.*`,
+		`(?i)a*b+`,
+		`(.)(.)\2\1`,
+		`(A)?B\1`,
+		`(?!.*AB).*`,
+		`.*(?<=A)B.*`,
+		`(?>A|AB)*B?`,
+	} {
+		re, err := Parse(expr, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n <= 5; n++ {
+			var want int64
+			Enumerate(re, n, alphabet, func(string) bool {
+				want++
+				return true
+			})
+			if c, exact := Count(re, n, alphabet); c.Cmp(big.NewInt(want)) != 0 || !exact {
+				t.Errorf("Count(%#q, %d) = %v, %v, want %d, true", expr, n, c, exact, want)
+			}
+		}
+	}
+}
+
+func TestCountBound(t *testing.T) {
+	re, err := Parse(`(.)\1.*`, Perl|Backref|Lookaround|Atomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alphabet := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	exact := new(big.Int).Exp(big.NewInt(26), big.NewInt(19), nil)
+	if c, ok := Count(re, 20, alphabet); c.Cmp(exact) < 0 || ok {
+		t.Errorf("Count = %v, %v, want at least %v, false", c, ok, exact)
+	}
+}
@@ -0,0 +1,110 @@
+package syntax
+
+import "sort"
+
+// Enumerate calls yield with each string of length n over alphabet
+// that re matches in full, in lexicographic order by rune, until
+// yield returns false.
+//
+// Strings are built one rune at a time and a prefix is abandoned as
+// soon as no string that extends it can match, so only the current
+// string is held. Regexps without backreferences, lookarounds or
+// atomic groups are run as the program of their length-constrained form, in which every
+// path has length n. Other regexps are matched over the syntax tree,
+// with the runes past the prefix unknown.
+func Enumerate(re *Regexp, n int, alphabet []rune, yield func(string) bool) {
+	if n < 0 {
+		return
+	}
+	runes, class := alphabetClass(alphabet)
+	e := &enumerator{runes: runes, buf: make([]rune, n), yield: yield}
+	re = re.Simplify().Mask(class)
+	if re.NeedsBacktrack() {
+		e.in = &prefixInput{n: n}
+		e.tree = NewMatcher(re, e.in, 0)
+		e.walkTree(0)
+		return
+	}
+	sized, err := ConstrainLength(re, n, n+1)
+	if err != nil {
+		return
+	}
+	prog, err := Compile(sized.Size(n))
+	if err != nil {
+		return
+	}
+	e.prog = prog
+	e.walkProg(0, []uint32{uint32(prog.Start)}, -1)
+}
+
+type enumerator struct {
+	runes []rune
+	buf   []rune
+	yield func(string) bool
+
+	prog *Prog
+
+	in   *prefixInput
+	tree *Matcher
+}
+
+// walkProg extends the prefix buf[:i], which leaves the program at
+// pcs after the rune before. It reports whether yield stopped the
+// enumeration.
+func (e *enumerator) walkProg(i int, pcs []uint32, before rune) bool {
+	if i == len(e.buf) {
+		for _, pc := range closure(e.prog, pcs, before, -1) {
+			if e.prog.Inst[pc].Op == InstMatch {
+				return !e.yield(string(e.buf))
+			}
+		}
+		return false
+	}
+	for _, r := range e.runes {
+		next := step(e.prog, closure(e.prog, pcs, before, r), r)
+		if len(next) == 0 {
+			continue
+		}
+		e.buf[i] = r
+		if e.walkProg(i+1, next, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkTree extends the prefix buf[:i] like walkProg, checking each
+// prefix against the syntax tree.
+func (e *enumerator) walkTree(i int) bool {
+	e.in.s = e.buf[:i]
+	if !e.tree.Match(func(j int) bool { return j == len(e.buf) }) {
+		return false
+	}
+	if i == len(e.buf) {
+		return !e.yield(string(e.buf))
+	}
+	for _, r := range e.runes {
+		e.buf[i] = r
+		if e.walkTree(i + 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// alphabetClass returns the distinct runes of alphabet in order and
+// as a character class.
+func alphabetClass(alphabet []rune) (runes, class []rune) {
+	runes = append(runes, alphabet...)
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == runes[i-1] {
+			runes = append(runes[:i], runes[i+1:]...)
+			i--
+		}
+	}
+	for _, r := range runes {
+		class = appendRange(class, r, r)
+	}
+	return runes, cleanClass(&class)
+}
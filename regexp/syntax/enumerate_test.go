@@ -0,0 +1,73 @@
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+var enumerateTests = []struct {
+	Regexp   string
+	Length   int
+	Alphabet string
+	Strings  string
+}{
+	{`[AB]*`, 2, "BA", "AA AB BA BB"},
+	{`HE|LL|O+`, 2, "EHLOP", "HE LL OO"},
+	{`[^E]L|.P`, 2, "ELP", "EP LL LP PL PP"},
+	{`A*`, 0, "A", ""},
+	{`A*`, -1, "A", "-"},
+	{`A+`, 3, "B", "-"},
+	{`\bA.\b`, 2, "A ", "AA"},
+	{`(?i)ab`, 2, "ABab", "AB Ab aB ab"},
+	{`(.)\1`, 2, "CBA", "AA BB CC"},
+	{`(.)(?i)\1`, 2, "aBA", "AA Aa BB aA aa"},
+	{`(...?)\1*`, 4, "AB", "AAAA ABAB BABA BBBB"},
+	{`(?:(A)|B)\1C`, 2, "ABC", "BC"},
+	{`(A|B)+\1`, 3, "AB", "AAA ABB BAA BBB"},
+	{`(?=.*A)..`, 2, "AB", "AA AB BA"},
+	{`(?!A)..`, 2, "AB", "BA BB"},
+	{`..(?<!AB)`, 2, "AB", "AA BA BB"},
+	{`(?=(.))..\1`, 3, "AB", "AAA ABA BAB BBB"},
+	{`A*+.`, 2, "AB", "AB"},
+	{`(?>A|AB)B?`, 2, "AB", "AB"},
+	{`(?>(A|B))\1?A`, 2, "AB", "AA BA"},
+}
+
+func TestEnumerate(t *testing.T) {
+	for _, tt := range enumerateTests {
+		re, err := Parse(tt.Regexp, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		var got []string
+		Enumerate(re, tt.Length, []rune(tt.Alphabet), func(s string) bool {
+			got = append(got, s)
+			return true
+		})
+		s := strings.Join(got, " ")
+		if got == nil {
+			s = "-"
+		}
+		if s != tt.Strings {
+			t.Errorf("Enumerate(%#q, %d, %q) = %q, want %q", tt.Regexp, tt.Length, tt.Alphabet, s, tt.Strings)
+		}
+	}
+}
+
+func TestEnumerateStop(t *testing.T) {
+	for _, expr := range []string{`.*`, `(.)\1*`, `(?!A).*`} {
+		re, err := Parse(expr, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		Enumerate(re, 3, []rune("XYZ"), func(s string) bool {
+			got = append(got, s)
+			return len(got) < 2
+		})
+		if len(got) != 2 {
+			t.Errorf("Enumerate(%#q) yielded %q after stopping", expr, got)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package syntax
+
+import (
+	"sort"
+	"unicode"
+)
+
+// IntersectLength returns a regexp that matches the strings of length
+// n that both a and b match. Like ConstrainLength, it approximates
+// backreferences by copies of the groups that they refer to, so for
+// regexps with backreferences the result may match more strings than
+// the intersection. The result matches nothing if there are no such
+// strings. It returns the error of ConstrainLength if a or b cannot be
+// constrained to length n, including when n is negative.
+//
+// The fixed-length forms of a and b are walked together, one rune at a
+// time, intersecting the classes of the runes that they match at each
+// position and branching at alternations.
+func IntersectLength(a, b *Regexp, n int) (*Regexp, error) {
+	sa, err := ConstrainLength(a.Simplify(), n, n+1)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := ConstrainLength(b.Simplify(), n, n+1)
+	if err != nil {
+		return nil, err
+	}
+	x := &intersecter{
+		seqs:  make(map[seq]*seq),
+		split: make(map[*Regexp][]*Regexp),
+		memo:  make(map[[2]*seq]*Regexp),
+	}
+	return x.intersect(x.push(sa.Size(n), nil), x.push(sb.Size(n), nil)), nil
+}
+
+// A seq is a list of regexps to be matched one after another. Seqs
+// are interned, so that equal lists are the same pointer and the
+// intersection of two lists is computed once.
+type seq struct {
+	re   *Regexp
+	next *seq
+}
+
+type intersecter struct {
+	seqs  map[seq]*seq
+	split map[*Regexp][]*Regexp // the runes of each literal
+	memo  map[[2]*seq]*Regexp
+}
+
+func (x *intersecter) push(re *Regexp, next *seq) *seq {
+	k := seq{re, next}
+	if s, ok := x.seqs[k]; ok {
+		return s
+	}
+	s := &seq{re, next}
+	x.seqs[k] = s
+	return s
+}
+
+// expand flattens the head of s until it is a single rune, an
+// alternation, an assertion or no match, or s is empty.
+func (x *intersecter) expand(s *seq) *seq {
+	for s != nil {
+		re := s.re
+		switch re.Op {
+		case OpEmptyMatch:
+			s = s.next
+		case OpConcat:
+			s = s.next
+			for i := len(re.Sub) - 1; i >= 0; i-- {
+				s = x.push(re.Sub[i], s)
+			}
+		case OpLiteral:
+			if len(re.Rune) == 1 {
+				return s
+			}
+			runes, ok := x.split[re]
+			if !ok {
+				for _, r := range re.Rune {
+					runes = append(runes, &Regexp{Op: OpLiteral, Flags: re.Flags, Rune: []rune{r}})
+				}
+				x.split[re] = runes
+			}
+			s = s.next
+			for i := len(runes) - 1; i >= 0; i-- {
+				s = x.push(runes[i], s)
+			}
+		case OpNoMatch, OpAlternate,
+			OpBeginLine, OpEndLine, OpBeginText, OpEndText,
+			OpWordBoundary, OpNoWordBoundary,
+			OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind,
+			OpCharClass, OpAnyCharNotNL, OpAnyChar:
+			return s
+		default:
+			panic("regexp: unhandled case in intersect")
+		}
+	}
+	return nil
+}
+
+// intersect returns a regexp for the strings that both xs and ys
+// match.
+func (x *intersecter) intersect(xs, ys *seq) *Regexp {
+	key := [2]*seq{xs, ys}
+	if re, ok := x.memo[key]; ok {
+		return re
+	}
+	re := x.step(x.expand(xs), x.expand(ys))
+	x.memo[key] = re
+	return re
+}
+
+func (x *intersecter) step(xs, ys *seq) *Regexp {
+	for _, s := range []*seq{xs, ys} {
+		if s == nil {
+			continue
+		}
+		switch s.re.Op {
+		case OpNoMatch:
+			return s.re
+		case OpAlternate:
+			alts := newSizedRegexp(0, 1)
+			for _, sub := range s.re.Sub {
+				var re *Regexp
+				if s == xs {
+					re = x.intersect(x.push(sub, xs.next), ys)
+				} else {
+					re = x.intersect(xs, x.push(sub, ys.next))
+				}
+				if re.Op != OpNoMatch {
+					alts.insert(re, 0)
+				}
+			}
+			return alts.Size(0)
+		case OpBeginLine, OpEndLine, OpBeginText, OpEndText,
+			OpWordBoundary, OpNoWordBoundary,
+			OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind:
+			var re *Regexp
+			if s == xs {
+				re = x.intersect(xs.next, ys)
+			} else {
+				re = x.intersect(xs, ys.next)
+			}
+			if re.Op == OpNoMatch {
+				return re
+			}
+			return concat2(s.re, re)
+		}
+	}
+	if xs == nil || ys == nil {
+		if xs == ys {
+			return &Regexp{Op: OpEmptyMatch}
+		}
+		return &Regexp{Op: OpNoMatch}
+	}
+	class := intersectCharClass(runeClass(xs.re), runeClass(ys.re))
+	if len(class) == 0 {
+		return &Regexp{Op: OpNoMatch}
+	}
+	rest := x.intersect(xs.next, ys.next)
+	if rest.Op == OpNoMatch {
+		return rest
+	}
+	return concat2(classRegexp(class), rest)
+}
+
+// runeClass returns the runes that re, which matches a single rune,
+// matches as a sorted list of range pairs.
+func runeClass(re *Regexp) []rune {
+	switch re.Op {
+	case OpCharClass:
+		return re.Rune
+	case OpAnyCharNotNL:
+		return anyRuneNotNL
+	case OpAnyChar:
+		return anyRune
+	}
+	r := re.Rune[0]
+	if re.Flags&FoldCase == 0 {
+		return []rune{r, r}
+	}
+	runes := []rune{r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		runes = append(runes, f)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	class := make([]rune, 0, 2*len(runes))
+	for _, r := range runes {
+		class = append(class, r, r)
+	}
+	return class
+}
+
+// classRegexp returns the simplest regexp that matches the runes of
+// class.
+func classRegexp(class []rune) *Regexp {
+	switch {
+	case len(class) == 2 && class[0] == class[1]:
+		return &Regexp{Op: OpLiteral, Rune: []rune{class[0]}}
+	case equalRunes(class, anyRune):
+		return &Regexp{Op: OpAnyChar}
+	case equalRunes(class, anyRuneNotNL):
+		return &Regexp{Op: OpAnyCharNotNL}
+	}
+	return &Regexp{Op: OpCharClass, Rune: class}
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
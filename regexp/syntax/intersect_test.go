@@ -0,0 +1,58 @@
+package syntax
+
+import "testing"
+
+var intersectLengthTests = []struct {
+	A, B   string
+	Length int
+	Want   string
+}{
+	{`A*`, `.*`, 3, `AAA`},
+	{`[AB]*`, `[BC]*`, 2, `BB`},
+	{`[A-M]*`, `[^ABC]*`, 1, `[D-M]`},
+	{`A*`, `B*`, 2, `[^\x00-\x{10FFFF}]`},
+	{`A*`, `B*`, 0, `(?:)`},
+	{`(AB|BA)*`, `A.*`, 4, `AB(?:AB|BA)`},
+	{`HE|LL|O+`, `[HELP]+`, 2, `HE|LL`},
+	{`(?i)ab`, `[aB][Bb]`, 2, `a[Bb]`},
+	{`.*`, `(?s:.)*`, 1, `(?-s:.)`},
+	{`^A.$`, `.B`, 2, `\AAB(?-m:$)`},
+	{`[AM]*CM(RC)*R?`, `.*RC?`, 4, `[AM]CMR|CMRC`},
+	{`(..)\1`, `AB.*`, 4, `AB(?-s:.)(?-s:.)`},
+	{`(?!.*B).*`, `[AB]A`, 2, `(?!B|(?-s:.)B)[A-B]A`},
+}
+
+func TestIntersectLength(t *testing.T) {
+	for _, tt := range intersectLengthTests {
+		a, err := Parse(tt.A, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.A, err)
+			continue
+		}
+		b, err := Parse(tt.B, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.B, err)
+			continue
+		}
+		re, err := IntersectLength(a, b, tt.Length)
+		if err != nil {
+			t.Errorf("IntersectLength(%#q, %#q, %d) = error %v", tt.A, tt.B, tt.Length, err)
+			continue
+		}
+		if s := re.String(); s != tt.Want {
+			t.Errorf("IntersectLength(%#q, %#q, %d) = %#q, want %#q", tt.A, tt.B, tt.Length, s, tt.Want)
+		}
+	}
+
+	a := &Regexp{Op: OpLiteral, Rune: []rune{'A'}}
+	_, err := IntersectLength(a, a, -1)
+	if e, ok := err.(*Error); !ok || e.Code != ErrInvalidLengthBounds {
+		t.Errorf("IntersectLength(%#q, %#q, -1) = error %v, want %s", a, a, err, ErrInvalidLengthBounds)
+	}
+	// The parser drops references to missing groups, so build one.
+	b := &Regexp{Op: OpConcat, Sub: []*Regexp{a, {Op: OpBackref, Cap: 1}}}
+	_, err = IntersectLength(a, b, 1)
+	if e, ok := err.(*Error); !ok || e.Code != ErrMissingCapture {
+		t.Errorf("IntersectLength(%#q, %#q, 1) = error %v, want %s", a, b, err, ErrMissingCapture)
+	}
+}
@@ -0,0 +1,414 @@
+package syntax
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// IsEmpty reports whether re matches no string in full, as when a
+// class excludes every rune or assertions contradict each other, and
+// whether the answer is exact. Regexps with backreferences,
+// lookarounds or atomic groups are decided as described on
+// Equivalent, and an answer of true is always exact.
+func IsEmpty(re *Regexp) (empty, exact bool) {
+	found, exact := decide([]*Regexp{re}, func(match []bool) bool {
+		return match[0]
+	})
+	return !found, exact || !found
+}
+
+// Subset reports whether b matches in full every string that a
+// matches in full, and whether the answer is exact. Regexps with
+// backreferences, lookarounds or atomic groups are decided as
+// described on Equivalent, and an answer of true is exact if b has
+// none of them, whatever a has.
+func Subset(a, b *Regexp) (subset, exact bool) {
+	found, exact := decide([]*Regexp{a, b}, func(match []bool) bool {
+		return match[0] && !match[1]
+	})
+	return !found, exact || !found && !b.NeedsBacktrack()
+}
+
+// Equivalent reports whether a and b match in full the same strings,
+// and whether the answer is exact.
+//
+// The regexps are compiled and their programs run together over every
+// string, one rune at a time, with the runes that neither program
+// tells apart taken as one, until the pairs of program states repeat.
+//
+// Backreferences are not regular, so a regexp with them is replaced by
+// one that matches every string that it does, and perhaps more: each
+// backreference is replaced by a copy of its group, made optional if
+// the group may not have matched, as in ConstrainLength, lookaround
+// assertions are taken to hold, and atomic groups may be backtracked
+// into. If the replacements match only strings of at most some length
+// and their backreferences do not ignore case, the regexps are instead
+// decided exactly by enumerating the strings that they match up to
+// that length, as long as there are at most 65536 of them, over an
+// alphabet with enough runes of each set that the programs do not tell
+// apart. Otherwise, the answer is for the replacements, a one-sided
+// bound that may be wrong for the regexps themselves, and exact is
+// false.
+func Equivalent(a, b *Regexp) (equiv, exact bool) {
+	found, exact := decide([]*Regexp{a, b}, func(match []bool) bool {
+		return match[0] != match[1]
+	})
+	return !found, exact
+}
+
+// decide reports whether found holds for some string, given which of
+// res match it in full, and whether the answer is exact, as described
+// on Equivalent.
+func decide(res []*Regexp, found func(match []bool) bool) (ok, exact bool) {
+	approx := make([]*Regexp, len(res))
+	tree := false
+	for i, re := range res {
+		re = re.Simplify()
+		tree = tree || re.NeedsBacktrack()
+		approx[i] = approxBackrefs(re)
+	}
+	ok = explore(approx, found)
+	if !tree {
+		return ok, true
+	}
+	if ok, exact := enumerateFound(res, approx, found); exact {
+		return ok, true
+	}
+	return ok, false
+}
+
+// enumerateFound decides like explore by enumerating the strings that
+// res match, given their approximations by approxBackrefs. It reports
+// exact false if it cannot, as described on Equivalent.
+func enumerateFound(res, approx []*Regexp, found func(match []bool) bool) (ok, exact bool) {
+	max := 0
+	progs := make([]*Prog, len(res))
+	for i, re := range res {
+		prog, err := Compile(re.Simplify())
+		if err != nil {
+			panic("regexp: " + err.Error())
+		}
+		progs[i] = prog
+		for _, inst := range prog.Inst {
+			if inst.Op == InstBackref && inst.Arg&1 != 0 {
+				// Renaming runes does not keep their case.
+				return false, false
+			}
+		}
+		n := maxLength(approx[i])
+		if n < 0 {
+			return false, false
+		}
+		if n > max {
+			max = n
+		}
+	}
+	alphabet := partitionRunes(partition(progs), max)
+
+	total := 0
+	match := make([]bool, len(res))
+	for n := 0; n <= max; n++ {
+		matched := make(map[string][]bool)
+		for i, re := range res {
+			Enumerate(re, n, alphabet, func(s string) bool {
+				if matched[s] == nil {
+					matched[s] = make([]bool, len(res))
+				}
+				matched[s][i] = true
+				total++
+				return total <= maxExactCount
+			})
+			if total > maxExactCount {
+				return false, false
+			}
+		}
+		for _, m := range matched {
+			copy(match, m)
+			if found(match) {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+// partitionRunes returns up to n runes from each of the ranges of runes
+// that start at the sorted runes reps, skipping surrogates. Regexps
+// that test runes only by those ranges and by equality match a string
+// of length at most n if and only if they match one over these runes,
+// which renaming the runes within each range gives.
+func partitionRunes(reps []rune, n int) []rune {
+	var runes []rune
+	for i, lo := range reps {
+		hi := rune(unicode.MaxRune)
+		if i+1 < len(reps) {
+			hi = reps[i+1] - 1
+		}
+		k := 0
+		for r := lo; r <= hi && k < n; r++ {
+			if 0xD800 <= r && r <= 0xDFFF {
+				continue
+			}
+			runes = append(runes, r)
+			k++
+		}
+	}
+	return runes
+}
+
+// maxLength returns the length of the longest string that re, which
+// has no backreferences, may match, or -1 if there is no bound.
+func maxLength(re *Regexp) int {
+	switch re.Op {
+	case OpLiteral:
+		return len(re.Rune)
+	case OpCharClass, OpAnyCharNotNL, OpAnyChar:
+		return 1
+	case OpCapture, OpQuest:
+		return maxLength(re.Sub[0])
+	case OpStar, OpPlus, OpRepeat:
+		n := maxLength(re.Sub[0])
+		switch {
+		case n == 0:
+			return 0
+		case n < 0 || re.Op != OpRepeat || re.Max < 0:
+			return -1
+		}
+		return n * re.Max
+	case OpConcat, OpAlternate:
+		total := 0
+		for _, sub := range re.Sub {
+			n := maxLength(sub)
+			if n < 0 {
+				return -1
+			}
+			if re.Op == OpConcat {
+				total += n
+			} else if n > total {
+				total = n
+			}
+		}
+		return total
+	}
+	return 0
+}
+
+// explore runs the programs of res, which have no backreferences,
+// lookarounds or atomic groups, together and reports whether found
+// holds for some string, given which of res match it in full.
+func explore(res []*Regexp, found func(match []bool) bool) bool {
+	progs := make([]*Prog, len(res))
+	for i, re := range res {
+		prog, err := Compile(re)
+		if err != nil {
+			panic("regexp: " + err.Error())
+		}
+		progs[i] = prog
+	}
+	reps := partition(progs)
+
+	// A state is the instructions that each program has reached,
+	// before following empty-width instructions, which depend on the
+	// rune before the state and the rune after it.
+	type state struct {
+		pcs    [][]uint32
+		before rune
+	}
+	start := state{make([][]uint32, len(progs)), -1}
+	for i, prog := range progs {
+		start.pcs[i] = []uint32{uint32(prog.Start)}
+	}
+	seen := map[string]bool{stateKey(start.pcs, start.before): true}
+	queue := []state{start}
+	match := make([]bool, len(progs))
+	for len(queue) != 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for i, prog := range progs {
+			match[i] = false
+			for _, pc := range closure(prog, s.pcs[i], s.before, -1) {
+				if prog.Inst[pc].Op == InstMatch {
+					match[i] = true
+				}
+			}
+		}
+		if found(match) {
+			return true
+		}
+		for _, r := range reps {
+			next := state{make([][]uint32, len(progs)), contextRune(r)}
+			for i, prog := range progs {
+				next.pcs[i] = step(prog, closure(prog, s.pcs[i], s.before, r), r)
+			}
+			key := stateKey(next.pcs, next.before)
+			if !seen[key] {
+				seen[key] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
+
+// approxBackrefs returns a regexp without backreferences, lookaround
+// assertions or atomic groups that matches every string that re does.
+// Backreferences are expanded by expandBackrefs, and those to missing
+// groups match any string.
+func approxBackrefs(re *Regexp) *Regexp {
+	return anyBackrefs(expandBackrefs(re))
+}
+
+// anyBackrefs replaces each backreference of re by (?s:.)*, each
+// lookaround assertion by the empty string and each atomic group by
+// its subexpression.
+func anyBackrefs(re *Regexp) *Regexp {
+	if isLookaround(re.Op) {
+		return &Regexp{Op: OpEmptyMatch}
+	}
+	if re.Op == OpAtomic {
+		return anyBackrefs(re.Sub[0])
+	}
+	if re.Op == OpBackref {
+		return &Regexp{Op: OpStar, Sub: []*Regexp{{Op: OpAnyChar}}}
+	}
+	if len(re.Sub) == 0 {
+		return re
+	}
+	return re.transform(anyBackrefs)
+}
+
+// partition returns a rune from each of the ranges of runes that no
+// instruction of progs, nor any empty-width assertion, tells apart.
+func partition(progs []*Prog) []rune {
+	starts := map[rune]bool{0: true}
+	add := func(lo, hi rune) {
+		starts[lo] = true
+		if hi < unicode.MaxRune {
+			starts[hi+1] = true
+		}
+	}
+	add('\n', '\n')
+	add('0', '9')
+	add('A', 'Z')
+	add('_', '_')
+	add('a', 'z')
+	for _, prog := range progs {
+		for _, inst := range prog.Inst {
+			switch inst.Op {
+			case InstRune, InstRune1:
+				if len(inst.Rune) == 1 {
+					r := inst.Rune[0]
+					add(r, r)
+					if Flags(inst.Arg)&FoldCase != 0 {
+						for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+							add(f, f)
+						}
+					}
+					break
+				}
+				for i := 0; i < len(inst.Rune); i += 2 {
+					add(inst.Rune[i], inst.Rune[i+1])
+				}
+			}
+		}
+	}
+	reps := make([]rune, 0, len(starts))
+	for r := range starts {
+		reps = append(reps, r)
+	}
+	sort.Slice(reps, func(i, j int) bool { return reps[i] < reps[j] })
+	return reps
+}
+
+// contextRune returns a rune that satisfies the same empty-width
+// assertions as r when before them.
+func contextRune(r rune) rune {
+	switch {
+	case r == '\n':
+		return '\n'
+	case IsWordChar(r):
+		return 'a'
+	}
+	return 0
+}
+
+// closure follows the instructions of pcs that consume no rune between
+// before and after, and returns the sorted instructions that consume a
+// rune or match.
+func closure(prog *Prog, pcs []uint32, before, after rune) []uint32 {
+	var out []uint32
+	seen := make(map[uint32]bool)
+	var visit func(pc uint32)
+	visit = func(pc uint32) {
+		if seen[pc] {
+			return
+		}
+		seen[pc] = true
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case InstAlt, InstAltMatch:
+			visit(inst.Out)
+			visit(inst.Arg)
+		case InstCapture, InstNop:
+			visit(inst.Out)
+		case InstEmptyWidth:
+			if inst.MatchEmptyWidth(before, after) {
+				visit(inst.Out)
+			}
+		case InstMatch, InstRune, InstRune1, InstRuneAny, InstRuneAnyNotNL:
+			out = append(out, pc)
+		case InstFail:
+		default:
+			panic("regexp: unhandled case in closure")
+		}
+	}
+	for _, pc := range pcs {
+		visit(pc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// step returns the sorted instructions that follow those of pcs that
+// consume r.
+func step(prog *Prog, pcs []uint32, r rune) []uint32 {
+	var out []uint32
+	for _, pc := range pcs {
+		inst := &prog.Inst[pc]
+		var ok bool
+		switch inst.Op {
+		case InstRune, InstRune1:
+			ok = inst.MatchRune(r)
+		case InstRuneAny:
+			ok = true
+		case InstRuneAnyNotNL:
+			ok = r != '\n'
+		}
+		if ok {
+			out = append(out, inst.Out)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	for i := 1; i < len(out); i++ {
+		if out[i] == out[i-1] {
+			out = append(out[:i], out[i+1:]...)
+			i--
+		}
+	}
+	return out
+}
+
+func stateKey(pcs [][]uint32, before rune) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(before)))
+	for _, list := range pcs {
+		b.WriteByte(';')
+		for _, pc := range list {
+			b.WriteString(strconv.Itoa(int(pc)))
+			b.WriteByte(',')
+		}
+	}
+	return b.String()
+}
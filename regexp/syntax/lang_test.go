@@ -0,0 +1,87 @@
+package syntax
+
+import "testing"
+
+var isEmptyTests = []struct {
+	Regexp       string
+	Empty, Exact bool
+}{
+	{`a*`, false, true},
+	{`(?:)`, false, true},
+	{`[^\x00-\x{10FFFF}]`, true, true},
+	{`a^b`, true, true},
+	{`a$b`, true, true},
+	{`(?m)a$\nb`, false, true},
+	{`a\bb`, true, true},
+	{`a\Bb`, false, true},
+	{`\b `, true, true},
+	{`(a|b)\1`, false, true},
+	{`(a)(?!a)\1`, true, true},
+	{`(a)(?<!a)\1`, true, true},
+	{`(?>a?)a`, false, true},
+	{`(?>a?)(?<=^)a`, true, true},
+	{`(?>a*)a`, false, false},
+	{`(a*)\1b`, false, false},
+}
+
+func TestIsEmpty(t *testing.T) {
+	for _, tt := range isEmptyTests {
+		re, err := Parse(tt.Regexp, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		if empty, exact := IsEmpty(re); empty != tt.Empty || exact != tt.Exact {
+			t.Errorf("IsEmpty(%#q) = %t, %t, want %t, %t", tt.Regexp, empty, exact, tt.Empty, tt.Exact)
+		}
+	}
+}
+
+var subsetTests = []struct {
+	A, B               string
+	Subset, Superset   bool
+	SubExact, SupExact bool
+}{
+	{`a*a`, `a+`, true, true, true, true},
+	{`(a|b)*`, `[ab]*`, true, true, true, true},
+	{`(?i)a`, `[Aa]`, true, true, true, true},
+	{`\ba\b`, `a`, true, true, true, true},
+	{`a+`, `a*`, true, false, true, true},
+	{`.`, `(?s:.)`, true, false, true, true},
+	{`[A-M]`, `[^X]`, true, false, true, true},
+	{`(AB)*`, `(BA)*`, false, false, true, true},
+	{`(ND|ET|IN)[^X]*`, `[DEINT]{2}.*`, false, false, true, true},
+	{`(ND|ET|IN)[^X\n]*`, `(?:N|E|I)[DTN].*`, true, false, true, true},
+	{`(a)\1`, `aa`, true, true, true, true},
+	{`(a)|\1`, `a|`, true, true, true, true},
+	{`(?:(a)|b)\1`, `(?:a|b)a?`, true, false, true, true},
+	{`(.)\1`, `..`, true, false, true, true},
+	{`(?=[a-c])..`, `[b-d].`, false, false, true, true},
+	{`(a)+\1`, `a+`, true, false, true, false},
+	{`(?i)(a)\1`, `(?i)aa`, true, true, true, false},
+}
+
+func TestSubset(t *testing.T) {
+	for _, tt := range subsetTests {
+		a, err := Parse(tt.A, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.A, err)
+			continue
+		}
+		b, err := Parse(tt.B, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.B, err)
+			continue
+		}
+		if sub, exact := Subset(a, b); sub != tt.Subset || exact != tt.SubExact {
+			t.Errorf("Subset(%#q, %#q) = %t, %t, want %t, %t", tt.A, tt.B, sub, exact, tt.Subset, tt.SubExact)
+		}
+		if sup, exact := Subset(b, a); sup != tt.Superset || exact != tt.SupExact {
+			t.Errorf("Subset(%#q, %#q) = %t, %t, want %t, %t", tt.B, tt.A, sup, exact, tt.Superset, tt.SupExact)
+		}
+		want, wantExact := tt.Subset && tt.Superset, tt.SubExact && tt.SupExact
+		if eq, exact := Equivalent(a, b); eq != want || exact != wantExact {
+			t.Errorf("Equivalent(%#q, %#q) = %t, %t, want %t, %t", tt.A, tt.B, eq, exact, want, wantExact)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package syntax
+
+import (
+	"sort"
+	"unicode"
+)
+
+// An Input is a string of fixed length for a Matcher to match, whose
+// runes may be known only in part. The matcher narrows the runes that
+// a position may hold as it goes, and undoes the narrowing when it
+// backtracks.
+type Input interface {
+	// Len returns the length of the string in runes.
+	Len() int
+
+	// Known reports whether every rune of the string is known.
+	Known() bool
+
+	// Narrow narrows position i to the runes that re matches at
+	// offset j, where re is a literal, character class or any
+	// character, as reported by MatchRune. It reports false if
+	// no rune remains.
+	Narrow(i int, re *Regexp, j int) bool
+
+	// NarrowWord narrows position i to the word characters, or to
+	// the others if word is false. It reports false if no rune
+	// remains.
+	NarrowWord(i int, word bool) bool
+
+	// Equal narrows positions i and j to runes that are equal, or
+	// equal up to case if fold is set. It reports false if there
+	// are none.
+	Equal(i, j int, fold bool) bool
+
+	// Mark returns a mark of the narrowing so far, and Undo
+	// undoes the narrowing since the mark.
+	Mark() int
+	Undo(mark int)
+}
+
+// A Matcher is a backtracking matcher that walks a syntax tree over an
+// Input. Every way to match the regexp that leaves some rune at each
+// position stands for the strings made of those runes, so a match is
+// possible if some string of the input may match, and exact once every
+// rune is known. It runs the regexps that programs only approximate.
+//
+// As in JavaScript, a reference to a group that has not participated
+// in the match matches the empty string, an iteration of a repetition
+// past its minimum must not match the empty string, and lookaround
+// assertions are atomic: once one holds, its match is not revisited.
+// Atomic groups likewise keep the first match of their subexpression.
+// Until every rune is known, a negative lookaround holds, and a
+// positive one or an atomic group may use any match of its
+// subexpression, so that no possible match is lost.
+type Matcher struct {
+	re      *Regexp
+	in      Input
+	caps    [][2]int
+	budget  int
+	limited bool
+}
+
+// NewMatcher returns a matcher of re over in. If budget is positive,
+// the matcher gives up after that many steps.
+func NewMatcher(re *Regexp, in Input, budget int) *Matcher {
+	return &Matcher{
+		re:      re,
+		in:      in,
+		caps:    make([][2]int, re.MaxCap()+1),
+		budget:  budget,
+		limited: budget > 0,
+	}
+}
+
+// Match matches the regexp from the start of the input and calls k
+// with each position at which the match could end, until k returns
+// true. It reports whether k did, or whether the matcher ran out of
+// steps, which Exhausted reports.
+func (m *Matcher) Match(k func(end int) bool) bool {
+	for i := range m.caps {
+		m.caps[i] = [2]int{-1, -1}
+	}
+	return m.match(m.re, 0, k)
+}
+
+// Exhausted reports whether the matcher ran out of steps, in which
+// case the results of Match are meaningless.
+func (m *Matcher) Exhausted() bool {
+	return m.limited && m.budget < 0
+}
+
+// MatchRunes reports whether re matches all of s.
+func MatchRunes(re *Regexp, s []rune) bool {
+	m := NewMatcher(re, &prefixInput{s: s, n: len(s)}, 0)
+	return m.Match(func(i int) bool {
+		return i == len(s)
+	})
+}
+
+// MatchRune reports whether r matches re at offset j, where re is a
+// literal, character class or any character.
+func (re *Regexp) MatchRune(j int, r rune) bool {
+	switch re.Op {
+	case OpLiteral:
+		return equalFold(r, re.Rune[j], re.Flags&FoldCase != 0)
+	case OpCharClass:
+		return inCharClass(r, re.Rune)
+	case OpAnyCharNotNL:
+		return r != '\n'
+	case OpAnyChar:
+		return true
+	}
+	return false
+}
+
+var newline = &Regexp{Op: OpLiteral, Rune: []rune{'\n'}}
+
+// match matches re at position i and calls k with each position at
+// which the match could end, until k returns true.
+func (m *Matcher) match(re *Regexp, i int, k func(int) bool) bool {
+	if m.limited {
+		if m.budget--; m.budget < 0 {
+			return true
+		}
+	}
+	n := m.in.Len()
+	switch re.Op {
+	case OpNoMatch:
+		return false
+	case OpEmptyMatch:
+		return k(i)
+	case OpLiteral, OpCharClass, OpAnyCharNotNL, OpAnyChar:
+		width := 1
+		if re.Op == OpLiteral {
+			width = len(re.Rune)
+		}
+		if i+width > n || re.Op == OpCharClass && len(re.Rune) == 0 {
+			return false
+		}
+		return m.narrow(func() bool {
+			for j := 0; j < width; j++ {
+				if !m.in.Narrow(i+j, re, j) {
+					return false
+				}
+			}
+			return true
+		}, func() bool { return k(i + width) })
+	case OpBeginLine:
+		return i == 0 && k(i) || i > 0 && m.narrow(func() bool {
+			return m.in.Narrow(i-1, newline, 0)
+		}, func() bool { return k(i) })
+	case OpEndLine:
+		return i == n && k(i) || i < n && m.narrow(func() bool {
+			return m.in.Narrow(i, newline, 0)
+		}, func() bool { return k(i) })
+	case OpBeginText:
+		return i == 0 && k(i)
+	case OpEndText:
+		return i == n && k(i)
+	case OpWordBoundary, OpNoWordBoundary:
+		return m.boundary(re.Op == OpWordBoundary, i, k)
+	case OpCapture:
+		return m.match(re.Sub[0], i, func(j int) bool {
+			old := m.caps[re.Cap]
+			m.caps[re.Cap] = [2]int{i, j}
+			if k(j) {
+				return true
+			}
+			m.caps[re.Cap] = old
+			return false
+		})
+	case OpBackref:
+		c := m.caps[re.Cap]
+		if c[0] < 0 {
+			return k(i)
+		}
+		w := c[1] - c[0]
+		if i+w > n {
+			return false
+		}
+		return m.narrow(func() bool {
+			for j := 0; j < w; j++ {
+				if !m.in.Equal(c[0]+j, i+j, re.Flags&FoldCase != 0) {
+					return false
+				}
+			}
+			return true
+		}, func() bool { return k(i + w) })
+	case OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind:
+		return m.look(re, i, k)
+	case OpAtomic:
+		if !m.in.Known() {
+			return m.match(re.Sub[0], i, k)
+		}
+		old := append([][2]int(nil), m.caps...)
+		end := -1
+		m.match(re.Sub[0], i, func(j int) bool {
+			end = j
+			return true
+		})
+		if end >= 0 && k(end) {
+			return true
+		}
+		copy(m.caps, old)
+		return false
+	case OpStar:
+		return m.repeat(re, i, 0, -1, k)
+	case OpPlus:
+		return m.repeat(re, i, 1, -1, k)
+	case OpQuest:
+		return m.repeat(re, i, 0, 1, k)
+	case OpRepeat:
+		return m.repeat(re, i, re.Min, re.Max, k)
+	case OpConcat:
+		return m.concat(re.Sub, i, k)
+	case OpAlternate:
+		for _, sub := range re.Sub {
+			if m.match(sub, i, k) {
+				return true
+			}
+		}
+		return false
+	}
+	panic("regexp: unhandled case in match")
+}
+
+// narrow narrows the input with f and, if every rune has not been
+// ruled out, continues with k, undoing the narrowing if k fails.
+func (m *Matcher) narrow(f, k func() bool) bool {
+	mark := m.in.Mark()
+	if f() && k() {
+		return true
+	}
+	m.in.Undo(mark)
+	return false
+}
+
+// boundary matches a word boundary, or a non-boundary if want is
+// false, at position i, trying each way for the runes on either side
+// to be word characters or not.
+func (m *Matcher) boundary(want bool, i int, k func(int) bool) bool {
+	n := m.in.Len()
+	for _, before := range []bool{false, true} {
+		after := before != want
+		if i == 0 && before || i == n && after {
+			continue
+		}
+		mark := m.in.Mark()
+		ok := (i == 0 || m.in.NarrowWord(i-1, before)) &&
+			(i == n || m.in.NarrowWord(i, after))
+		if ok && k(i) {
+			return true
+		}
+		if ok && m.in.Mark() == mark {
+			// Nothing was narrowed, so the other way is the
+			// same or impossible.
+			return false
+		}
+		m.in.Undo(mark)
+	}
+	return false
+}
+
+// look matches the lookaround assertion re at position i.
+func (m *Matcher) look(re *Regexp, i int, k func(int) bool) bool {
+	behind := re.Op == OpLookbehind || re.Op == OpNegLookbehind
+	negate := re.Op == OpNegLookahead || re.Op == OpNegLookbehind
+	try := func(k1 func() bool) bool {
+		lo := i
+		if behind {
+			lo = 0
+		}
+		for j := lo; j <= i; j++ {
+			if m.match(re.Sub[0], j, func(end int) bool {
+				return (!behind || end == i) && k1()
+			}) {
+				return true
+			}
+		}
+		return false
+	}
+	if !m.in.Known() {
+		if negate {
+			return k(i)
+		}
+		return try(func() bool { return k(i) })
+	}
+	old := append([][2]int(nil), m.caps...)
+	matched := try(func() bool { return true })
+	if negate {
+		copy(m.caps, old)
+		return !matched && k(i)
+	}
+	if matched && k(i) {
+		return true
+	}
+	copy(m.caps, old)
+	return false
+}
+
+func (m *Matcher) concat(subs []*Regexp, i int, k func(int) bool) bool {
+	if len(subs) == 0 {
+		return k(i)
+	}
+	return m.match(subs[0], i, func(j int) bool {
+		return m.concat(subs[1:], j, k)
+	})
+}
+
+// repeat matches the subexpression of the repetition re at least min
+// and at most max times (max == -1 is no limit), trying more
+// iterations first unless re is non-greedy. Once min is reached,
+// iterations must make progress, so that empty matches cannot loop
+// forever.
+func (m *Matcher) repeat(re *Regexp, i, min, max int, k func(int) bool) bool {
+	lazy := re.Flags&NonGreedy != 0
+	if lazy && min == 0 && k(i) {
+		return true
+	}
+	if max != 0 && m.match(re.Sub[0], i, func(j int) bool {
+		if min == 0 && j == i {
+			return false
+		}
+		next := max
+		if max > 0 {
+			next--
+		}
+		prev := min
+		if min > 0 {
+			prev--
+		}
+		return m.repeat(re, j, prev, next, k)
+	}) {
+		return true
+	}
+	return !lazy && min == 0 && k(i)
+}
+
+// A prefixInput is a string of length n of which only the prefix s is
+// known. The runes past the prefix may be any rune.
+type prefixInput struct {
+	s []rune
+	n int
+}
+
+func (in *prefixInput) Len() int    { return in.n }
+func (in *prefixInput) Known() bool { return len(in.s) == in.n }
+func (in *prefixInput) Mark() int   { return 0 }
+func (in *prefixInput) Undo(int)    {}
+
+func (in *prefixInput) known(i int) bool {
+	return i < len(in.s)
+}
+
+func (in *prefixInput) Narrow(i int, re *Regexp, j int) bool {
+	return !in.known(i) || re.MatchRune(j, in.s[i])
+}
+
+func (in *prefixInput) NarrowWord(i int, word bool) bool {
+	return !in.known(i) || IsWordChar(in.s[i]) == word
+}
+
+func (in *prefixInput) Equal(i, j int, fold bool) bool {
+	return !in.known(i) || !in.known(j) || equalFold(in.s[j], in.s[i], fold)
+}
+
+// equalFold reports whether r matches the literal rune lit.
+func equalFold(r, lit rune, foldCase bool) bool {
+	if r == lit {
+		return true
+	}
+	if foldCase {
+		for f := unicode.SimpleFold(lit); f != lit; f = unicode.SimpleFold(f) {
+			if r == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inCharClass reports whether r is in class, a sorted list of range
+// pairs.
+func inCharClass(r rune, class []rune) bool {
+	i := sort.Search(len(class)/2, func(i int) bool {
+		return class[2*i+1] >= r
+	})
+	return i < len(class)/2 && class[2*i] <= r
+}
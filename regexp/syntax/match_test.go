@@ -0,0 +1,51 @@
+package syntax
+
+import "testing"
+
+var matchRunesTests = []struct {
+	Regexp string
+	Text   string
+	Match  bool
+}{
+	{`(a)\1`, "aa", true},
+	{`(a)\1`, "ab", false},
+	{`(?:(a)|b)\1c`, "bc", true},
+	{`(a*)+b\1`, "ab", false},
+	{`(a*)+b\1`, "aab", false},
+	{`(a|)+\1b`, "ab", false},
+	{`(?i)(a)\1`, "aA", true},
+	{`(?m)a$\n^b`, "a\nb", true},
+	{`\ba\b`, "a", true},
+	{`a\Bb`, "ab", true},
+	{`(?=a)ab`, "ab", true},
+	{`(?!a)ab`, "ab", false},
+	{`a(?<=(a))\1`, "aa", true},
+	{`(?>a|ab)b`, "ab", true},
+	{`(?>a|ab)`, "ab", false},
+	{`a*+a`, "aa", false},
+}
+
+func TestMatchRunes(t *testing.T) {
+	for _, tt := range matchRunesTests {
+		re, err := Parse(tt.Regexp, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		if m := MatchRunes(re.Simplify(), []rune(tt.Text)); m != tt.Match {
+			t.Errorf("MatchRunes(%#q, %q) = %v, want %v", tt.Regexp, tt.Text, m, tt.Match)
+		}
+	}
+}
+
+func TestMatcherBudget(t *testing.T) {
+	re, err := Parse(`(?:a|a)*(?:a|a)*(?:a|a)*b`, Perl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMatcher(re, &prefixInput{s: []rune("aaaaaaaaaaaa"), n: 12}, 1000)
+	m.Match(func(i int) bool { return i == 12 })
+	if !m.Exhausted() {
+		t.Errorf("matcher of %#q did not run out of steps", re)
+	}
+}
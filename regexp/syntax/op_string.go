@@ -28,21 +28,26 @@ func _() {
 	_ = x[OpRepeat-18]
 	_ = x[OpConcat-19]
 	_ = x[OpAlternate-20]
+	_ = x[OpLookahead-21]
+	_ = x[OpNegLookahead-22]
+	_ = x[OpLookbehind-23]
+	_ = x[OpNegLookbehind-24]
+	_ = x[OpAtomic-25]
 	_ = x[opPseudo-128]
 }
 
 const (
-	_Op_name_0 = "NoMatchEmptyMatchLiteralCharClassAnyCharNotNLAnyCharBeginLineEndLineBeginTextEndTextWordBoundaryNoWordBoundaryBackrefCaptureStarPlusQuestRepeatConcatAlternate"
+	_Op_name_0 = "NoMatchEmptyMatchLiteralCharClassAnyCharNotNLAnyCharBeginLineEndLineBeginTextEndTextWordBoundaryNoWordBoundaryBackrefCaptureStarPlusQuestRepeatConcatAlternateLookaheadNegLookaheadLookbehindNegLookbehindAtomic"
 	_Op_name_1 = "opPseudo"
 )
 
 var (
-	_Op_index_0 = [...]uint8{0, 7, 17, 24, 33, 45, 52, 61, 68, 77, 84, 96, 110, 117, 124, 128, 132, 137, 143, 149, 158}
+	_Op_index_0 = [...]uint8{0, 7, 17, 24, 33, 45, 52, 61, 68, 77, 84, 96, 110, 117, 124, 128, 132, 137, 143, 149, 158, 167, 179, 189, 202, 208}
 )
 
 func (i Op) String() string {
 	switch {
-	case 1 <= i && i <= 20:
+	case 1 <= i && i <= 25:
 		i -= 1
 		return _Op_name_0[_Op_index_0[i]:_Op_index_0[i+1]]
 	case i == 128:
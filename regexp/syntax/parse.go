@@ -44,6 +44,11 @@ const (
 	ErrMissingRepeatArgument ErrorCode = "missing argument to repetition operator"
 	ErrTrailingBackslash     ErrorCode = "trailing backslash at end of expression"
 	ErrUnexpectedParen       ErrorCode = "unexpected )"
+
+	// Length constraint errors
+	ErrInvalidLengthBounds ErrorCode = "invalid length bounds"
+	ErrMissingCapture      ErrorCode = "backreference to missing capture"
+	ErrUnsimplifiedRepeat  ErrorCode = "repeat not simplified"
 )
 
 func (e ErrorCode) String() string {
@@ -64,8 +69,10 @@ const (
 	UnicodeGroups                       // allow \p{Han}, \P{Han} for Unicode group and negation
 	WasDollar                           // regexp OpEndText was $, not \z
 	Simple                              // regexp contains no counted repetition
-	Backref                             // allow backreferences
+	Backref                             // allow backreferences and (?<name>re)
 	PermissiveEscapes                   // allow \uxxxx, \u{xxxxx}, and \e
+	Lookaround                          // allow (?=re), (?!re), (?<=re), and (?<!re)
+	Atomic                              // allow (?>re) and possessive repetitions like x*+
 
 	MatchNL = ClassNL | DotNL
 
@@ -228,15 +235,21 @@ func (p *parser) op(op Op) *Regexp {
 // repeat returns an updated 'after' and an error, if any.
 func (p *parser) repeat(op Op, min, max int, before, after, lastRepeat string) (string, error) {
 	flags := p.flags
+	possessive := false
 	if p.flags&PerlX != 0 {
 		if len(after) > 0 && after[0] == '?' {
 			after = after[1:]
 			flags ^= NonGreedy
+		} else if len(after) > 0 && after[0] == '+' && p.flags&Atomic != 0 {
+			// A possessive repetition is an atomic group
+			// around the repetition.
+			after = after[1:]
+			possessive = true
 		}
 		if lastRepeat != "" {
 			// In Perl it is not allowed to stack repetition operators:
 			// a** is a syntax error, not a doubled star, and a++ means
-			// something else entirely, which we don't support!
+			// something else entirely, which only Atomic supports.
 			return "", &Error{ErrInvalidRepeatOp, lastRepeat[:len(lastRepeat)-len(after)]}
 		}
 	}
@@ -261,6 +274,14 @@ func (p *parser) repeat(op Op, min, max int, before, after, lastRepeat string) (
 		return "", &Error{ErrInvalidRepeatSize, before[:len(before)-len(after)]}
 	}
 
+	if possessive {
+		atomic := p.newRegexp(OpAtomic)
+		atomic.Flags = flags
+		atomic.Sub = atomic.Sub0[:1]
+		atomic.Sub[0] = re
+		p.stack[n-1] = atomic
+	}
+
 	return after, nil
 }
 
@@ -376,7 +397,6 @@ func (p *parser) collapse(subs []*Regexp, op Op) *Regexp {
 	}
 	re := p.newRegexp(op)
 	re.Sub = re.Sub0[:0]
-	quest := false
 	for _, sub := range subs {
 		switch op {
 		case OpConcat:
@@ -389,18 +409,8 @@ func (p *parser) collapse(subs []*Regexp, op Op) *Regexp {
 				continue
 			}
 		case OpAlternate:
-			switch sub.Op {
-			case OpQuest:
-				re.Sub = append(re.Sub, sub.Sub...)
-				p.reuse(sub)
-				quest = true
-				continue
-			case OpNoMatch:
-				p.reuse(sub)
-				continue
-			case OpEmptyMatch:
+			if sub.Op == OpNoMatch {
 				p.reuse(sub)
-				quest = true
 				continue
 			}
 		}
@@ -412,26 +422,97 @@ func (p *parser) collapse(subs []*Regexp, op Op) *Regexp {
 		}
 	}
 	if op == OpAlternate {
+		var quest *Regexp
+		if p.flags&Atomic != 0 {
+			re.Sub, quest = p.hoistEmpty(re.Sub)
+		}
 		re.Sub = p.factor(re.Sub)
 		switch len(re.Sub) {
 		case 0:
 			re.Op = OpEmptyMatch
 			re.Sub = nil
+			if quest != nil {
+				p.reuse(quest)
+			}
 			return re
 		case 1:
 			old := re
 			re = re.Sub[0]
 			p.reuse(old)
 		}
-		if quest {
-			q := p.newRegexp(OpQuest)
-			q.Sub = append(q.Sub[:0], re)
-			re = q
+		if quest != nil {
+			quest.Sub = append(quest.Sub[:0], re)
+			re = quest
 		}
 	}
 	return re
 }
 
+// hoistEmpty drops the empty alternatives of the alternation list sub
+// that can never be taken, as they follow another way to match the
+// empty string. If then only the last alternative may match the empty
+// string, being empty or a greedy quest, or only the first may, being
+// empty or a non-greedy quest, it is replaced by its subexpression, or
+// removed if empty, and hoistEmpty returns a quest to put around the
+// alternation, greedy for the last and non-greedy for the first.
+// Other empty alternatives are kept in place, since hoisting them
+// would change the order in which the alternatives are tried, which
+// submatches, atomic groups and lookarounds observe. It is only used
+// with the Atomic flag, so that other regexps keep their alternatives
+// as written.
+func (p *parser) hoistEmpty(sub []*Regexp) ([]*Regexp, *Regexp) {
+	out := sub[:0]
+	empty := false
+	for _, re := range sub {
+		switch re.Op {
+		case OpEmptyMatch:
+			if empty {
+				p.reuse(re)
+				continue
+			}
+			empty = true
+		case OpQuest:
+			empty = true
+		}
+		out = append(out, re)
+	}
+	sub = out
+
+	may := -1
+	for i, re := range sub {
+		if re.Op == OpEmptyMatch || re.Op == OpQuest {
+			if may >= 0 {
+				return sub, nil
+			}
+			may = i
+		}
+	}
+	if may < 0 || len(sub) == 1 && sub[0].Op == OpEmptyMatch {
+		return sub, nil
+	}
+	alt := sub[may]
+	lazy := alt.Op == OpQuest && alt.Flags&NonGreedy != 0
+	switch {
+	case may == len(sub)-1 && !lazy:
+	case may == 0 && (alt.Op == OpEmptyMatch || lazy):
+		lazy = true
+	default:
+		return sub, nil
+	}
+	quest := p.newRegexp(OpQuest)
+	if alt.Op == OpQuest {
+		quest.Flags = alt.Flags
+		sub[may] = alt.Sub[0]
+		p.reuse(alt)
+		return sub, quest
+	}
+	if lazy {
+		quest.Flags = NonGreedy
+	}
+	p.reuse(alt)
+	return append(sub[:may], sub[may+1:]...), quest
+}
+
 // factor factors common prefixes from the alternation list sub.
 // It returns a replacement list that reuses the same storage and
 // frees (passes to p.reuse) any removed *Regexps.
@@ -1018,8 +1099,17 @@ func (p *parser) parsePerlFlags(s string) (rest string, err error) {
 	//
 	// In both the open source world (via Code Search) and the
 	// Google source tree, (?P<expr>name) is the dominant form,
-	// so that's the one we implement. One is enough.
-	if len(t) > 4 && t[2] == 'P' && t[3] == '<' {
+	// so that's the one we implement. One is enough. With Backref,
+	// the .NET form is accepted too, since it is the one that
+	// JavaScript uses along with \k<name>.
+	start := 0
+	switch {
+	case len(t) > 4 && t[2] == 'P' && t[3] == '<':
+		start = 4
+	case p.flags&Backref != 0 && len(t) > 3 && t[2] == '<' && t[3] != '=' && t[3] != '!':
+		start = 3
+	}
+	if start > 0 {
 		// Pull out name.
 		end := strings.IndexRune(t, '>')
 		if end < 0 {
@@ -1029,8 +1119,8 @@ func (p *parser) parsePerlFlags(s string) (rest string, err error) {
 			return "", &Error{ErrInvalidNamedCapture, s}
 		}
 
-		capture := t[:end+1] // "(?P<name>"
-		name := t[4:end]     // "name"
+		capture := t[:end+1] // "(?P<name>" or "(?<name>"
+		name := t[start:end] // "name"
 		if err = checkUTF8(name); err != nil {
 			return "", err
 		}
@@ -1047,6 +1137,31 @@ func (p *parser) parsePerlFlags(s string) (rest string, err error) {
 		return t[end+1:], nil
 	}
 
+	// Lookaround assertions, as in Perl and JavaScript, and atomic
+	// groups, as in Perl. The left paren records the op in Min until
+	// its right paren.
+	if p.flags&Atomic != 0 && strings.HasPrefix(t, "(?>") {
+		p.op(opLeftParen).Min = int(OpAtomic)
+		return t[3:], nil
+	}
+	if p.flags&Lookaround != 0 {
+		var op Op
+		switch {
+		case strings.HasPrefix(t, "(?="):
+			op, t = OpLookahead, t[3:]
+		case strings.HasPrefix(t, "(?!"):
+			op, t = OpNegLookahead, t[3:]
+		case strings.HasPrefix(t, "(?<="):
+			op, t = OpLookbehind, t[4:]
+		case strings.HasPrefix(t, "(?<!"):
+			op, t = OpNegLookbehind, t[4:]
+		}
+		if op != 0 {
+			p.op(opLeftParen).Min = int(op)
+			return t, nil
+		}
+	}
+
 	// Non-capturing group. Might also twiddle Perl flags.
 	var c rune
 	t = t[2:] // skip (?
@@ -1285,7 +1400,13 @@ func (p *parser) parseRightParen() error {
 	}
 	// Restore flags at time of paren.
 	p.flags = re2.Flags
-	if re2.Cap == 0 {
+	if re2.Min != 0 {
+		re2.Op = Op(re2.Min)
+		re2.Min = 0
+		re2.Sub = re2.Sub0[:1]
+		re2.Sub[0] = re1
+		p.push(re2)
+	} else if re2.Cap == 0 {
 		// Just for grouping.
 		p.push(re1)
 	} else {
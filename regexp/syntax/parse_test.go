@@ -261,6 +261,100 @@ func TestParseNoMatchNL(t *testing.T) {
 	testParseDump(t, nomatchnlTests, 0)
 }
 
+var lookaroundTests = []parseTest{
+	{`a(?=b)`, `cat{lit{a}la{lit{b}}}`},
+	{`a(?!b|cd)`, `cat{lit{a}nla{alt{lit{b}str{cd}}}}`},
+	{`(?<=a)b`, `cat{lb{lit{a}}lit{b}}`},
+	{`(?<!(a))b`, `cat{nlb{cap{lit{a}}}lit{b}}`},
+	{`(?=)`, `la{emp{}}`},
+	{`(?=a)*`, `star{la{lit{a}}}`},
+	{`(?=(?<!a)b)`, `la{cat{nlb{lit{a}}lit{b}}}`},
+}
+
+func TestParseLookaround(t *testing.T) {
+	testParseDump(t, lookaroundTests, Perl|Lookaround)
+	for _, tt := range lookaroundTests {
+		if _, err := Parse(tt.Regexp, Perl); err == nil {
+			t.Errorf("Parse(%#q, Perl) succeeded without Lookaround", tt.Regexp)
+		}
+		re, err := Parse(tt.Regexp, Perl|Lookaround)
+		if err != nil {
+			continue
+		}
+		if s := re.String(); s != tt.Regexp {
+			t.Errorf("Parse(%#q).String() = %#q", tt.Regexp, s)
+		}
+	}
+}
+
+var backrefTests = []parseTest{
+	{`(a)\1`, `cat{cap{lit{a}}bac{1}}`},
+	{`(?P<x>a)\k<x>`, `cat{cap{x:lit{a}}bac{1,x}}`},
+	{`(?<x>a)\k<x>`, `cat{cap{x:lit{a}}bac{1,x}}`},
+}
+
+func TestParseBackref(t *testing.T) {
+	testParseDump(t, backrefTests, Perl|Backref)
+	for _, tt := range backrefTests {
+		if _, err := Parse(tt.Regexp, Perl); err == nil {
+			t.Errorf("Parse(%#q, Perl) succeeded without Backref", tt.Regexp)
+		}
+	}
+	for _, expr := range []string{`(?<>a)`, `(?<x y>a)`, `(?<x>a`} {
+		if re, err := Parse(expr, Perl|Backref); err == nil {
+			t.Errorf("Parse(%#q, Perl|Backref) = %s, should have failed", expr, dump(re))
+		}
+	}
+	if re, err := Parse(`(?<=a)(?<!b)`, Perl|Backref|Lookaround); err != nil {
+		t.Errorf("Parse(%#q, Perl|Backref|Lookaround): %v", `(?<=a)(?<!b)`, err)
+	} else if d, want := dump(re), `cat{lb{lit{a}}nlb{lit{b}}}`; d != want {
+		t.Errorf("Parse(%#q).Dump() = %#q want %#q", `(?<=a)(?<!b)`, d, want)
+	}
+}
+
+var atomicTests = []parseTest{
+	{`(?>ab)`, `atom{str{ab}}`},
+	{`(?>a|b)c`, `cat{atom{cc{0x61-0x62}}lit{c}}`},
+	{`a*+`, `atom{star{lit{a}}}`},
+	{`a++b`, `cat{atom{plus{lit{a}}}lit{b}}`},
+	{`a?+`, `atom{que{lit{a}}}`},
+	{`a{2,3}+`, `atom{rep{2,3 lit{a}}}`},
+	{`(?U)a*+`, `atom{nstar{lit{a}}}`},
+	{`(?>a)*`, `star{atom{lit{a}}}`},
+	{`(?>a|ab)`, `atom{cat{lit{a}nque{lit{b}}}}`},
+	{`(?>ab|a)`, `atom{cat{lit{a}que{lit{b}}}}`},
+	{`(?>|a)`, `atom{nque{lit{a}}}`},
+	{`(?>a?|b)`, `atom{alt{que{lit{a}}lit{b}}}`},
+	{`(?>b|a?)`, `atom{que{cc{0x61-0x62}}}`},
+	{`(?>a||b)`, `atom{alt{lit{a}emp{}lit{b}}}`},
+}
+
+func TestParseAtomic(t *testing.T) {
+	testParseDump(t, atomicTests, Perl|Atomic)
+	for _, tt := range atomicTests {
+		if _, err := Parse(tt.Regexp, Perl); err == nil {
+			t.Errorf("Parse(%#q, Perl) succeeded without Atomic", tt.Regexp)
+		}
+		re, err := Parse(tt.Regexp, Perl|Atomic)
+		if err != nil {
+			continue
+		}
+		nre, err := Parse(re.String(), Perl|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q.String() = %#q): %v", tt.Regexp, re.String(), err)
+			continue
+		}
+		if d, nd := dump(re), dump(nre); d != nd {
+			t.Errorf("Parse(%#q) -> %#q; %#q vs %#q", tt.Regexp, re.String(), d, nd)
+		}
+	}
+	for _, expr := range []string{`a*?+`, `a+++`, `a*+*`, `(?>a`} {
+		if re, err := Parse(expr, Perl|Atomic); err == nil {
+			t.Errorf("Parse(%#q, Perl|Atomic) = %s, should have failed", expr, dump(re))
+		}
+	}
+}
+
 // Test Parse -> Dump.
 func testParseDump(t *testing.T, tests []parseTest, flags Flags) {
 	for _, tt := range tests {
@@ -309,6 +403,11 @@ var opNames = []string{
 	OpRepeat:         "rep",
 	OpConcat:         "cat",
 	OpAlternate:      "alt",
+	OpLookahead:      "la",
+	OpNegLookahead:   "nla",
+	OpLookbehind:     "lb",
+	OpNegLookbehind:  "nlb",
+	OpAtomic:         "atom",
 }
 
 // dumpRegexp writes an encoding of the syntax tree for the regexp re to b.
@@ -356,7 +455,8 @@ func dumpRegexp(b *strings.Builder, re *Regexp) {
 		for _, sub := range re.Sub {
 			dumpRegexp(b, sub)
 		}
-	case OpStar, OpPlus, OpQuest:
+	case OpStar, OpPlus, OpQuest,
+		OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind, OpAtomic:
 		dumpRegexp(b, re.Sub[0])
 	case OpRepeat:
 		fmt.Fprintf(b, "%d,%d ", re.Min, re.Max)
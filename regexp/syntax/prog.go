@@ -15,9 +15,10 @@ import (
 
 // A Prog is a compiled regular expression program.
 type Prog struct {
-	Inst   []Inst
-	Start  int // index of start instruction
-	NumCap int // number of InstCapture insts in re
+	Inst    []Inst
+	Start   int // index of start instruction
+	NumCap  int // number of InstCapture insts in re
+	NumLoop int // number of loop registers, for InstLoop and InstProgress
 }
 
 // An InstOp is an instruction opcode.
@@ -35,6 +36,14 @@ const (
 	InstRune1
 	InstRuneAny
 	InstRuneAnyNotNL
+	InstBackref
+	InstLookahead
+	InstNegLookahead
+	InstLookbehind
+	InstNegLookbehind
+	InstAtomic
+	InstLoop
+	InstProgress
 )
 
 var instOpNames = []string{
@@ -49,6 +58,14 @@ var instOpNames = []string{
 	"InstRune1",
 	"InstRuneAny",
 	"InstRuneAnyNotNL",
+	"InstBackref",
+	"InstLookahead",
+	"InstNegLookahead",
+	"InstLookbehind",
+	"InstNegLookbehind",
+	"InstAtomic",
+	"InstLoop",
+	"InstProgress",
 }
 
 func (i InstOp) String() string {
@@ -112,7 +129,7 @@ func IsWordChar(r rune) bool {
 type Inst struct {
 	Op   InstOp
 	Out  uint32 // all but InstMatch, InstFail
-	Arg  uint32 // InstAlt, InstAltMatch, InstCapture, InstEmptyWidth
+	Arg  uint32 // InstAlt, InstAltMatch, InstCapture, InstEmptyWidth, InstBackref (see compiler.backref), lookarounds, InstAtomic, InstLoop (see compiler.loop), InstProgress
 	Rune []rune
 }
 
@@ -342,5 +359,29 @@ func dumpInst(b *strings.Builder, i *Inst) {
 		bw(b, "any -> ", u32(i.Out))
 	case InstRuneAnyNotNL:
 		bw(b, "anynotnl -> ", u32(i.Out))
+	case InstBackref:
+		bw(b, "backref ", u32(i.Arg>>1))
+		if i.Arg&1 != 0 {
+			bw(b, "/i")
+		}
+		bw(b, " -> ", u32(i.Out))
+	case InstLookahead:
+		bw(b, "lookahead ", u32(i.Arg), " -> ", u32(i.Out))
+	case InstNegLookahead:
+		bw(b, "neglookahead ", u32(i.Arg), " -> ", u32(i.Out))
+	case InstLookbehind:
+		bw(b, "lookbehind ", u32(i.Arg), " -> ", u32(i.Out))
+	case InstNegLookbehind:
+		bw(b, "neglookbehind ", u32(i.Arg), " -> ", u32(i.Out))
+	case InstAtomic:
+		bw(b, "atomic ", u32(i.Arg), " -> ", u32(i.Out))
+	case InstLoop:
+		bw(b, "loop ", u32(i.Arg>>1))
+		if i.Arg&1 != 0 {
+			bw(b, "/first")
+		}
+		bw(b, " -> ", u32(i.Out))
+	case InstProgress:
+		bw(b, "progress ", u32(i.Arg), " -> ", u32(i.Out))
 	}
 }
@@ -88,12 +88,38 @@ var compileTests = []struct {
   1*	empty 4 -> 2
   2	anynotnl -> 3
   3	match
+`},
+	{`(a)\1`, `  0	fail
+  1*	cap 2 -> 2
+  2	rune1 "a" -> 3
+  3	cap 3 -> 4
+  4	backref 1 -> 5
+  5	match
+`},
+	{`(a)(?i)\1`, `  0	fail
+  1*	cap 2 -> 2
+  2	rune1 "a" -> 3
+  3	cap 3 -> 4
+  4	backref 1/i -> 5
+  5	match
+`},
+	{`(a|)*\1`, `  0	fail
+  1	cap 2 -> 4
+  2	rune1 "a" -> 5
+  3	nop -> 5
+  4	alt -> 2, 3
+  5	cap 3 -> 7
+  6	loop 0 -> 1
+  7	progress 0 -> 8
+  8*	alt -> 6, 9
+  9	backref 1 -> 10
+ 10	match
 `},
 }
 
 func TestCompile(t *testing.T) {
 	for _, tt := range compileTests {
-		re, _ := Parse(tt.Regexp, Perl)
+		re, _ := Parse(tt.Regexp, Perl|Backref)
 		p, _ := Compile(re)
 		s := p.String()
 		if s != tt.Prog {
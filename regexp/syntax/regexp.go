@@ -56,10 +56,21 @@ const (
 	OpRepeat                       // matches Sub[0] at least Min times, at most Max (Max == -1 is no limit)
 	OpConcat                       // matches concatenation of Subs
 	OpAlternate                    // matches alternation of Subs
+	OpLookahead                    // matches empty string if Sub[0] matches after it
+	OpNegLookahead                 // matches empty string if Sub[0] does not match after it
+	OpLookbehind                   // matches empty string if Sub[0] matches before it
+	OpNegLookbehind                // matches empty string if Sub[0] does not match before it
+	OpAtomic                       // matches Sub[0] without backtracking into it
 )
 
 const opPseudo Op = 128 // where pseudo-ops start
 
+// isLookaround reports whether op is a lookahead or lookbehind
+// assertion.
+func isLookaround(op Op) bool {
+	return OpLookahead <= op && op <= OpNegLookbehind
+}
+
 // Equal reports whether x and y have identical structure.
 func (x *Regexp) Equal(y *Regexp) bool {
 	if x == nil || y == nil {
@@ -109,6 +120,11 @@ func (x *Regexp) Equal(y *Regexp) bool {
 		if x.Cap != y.Cap || x.Name != y.Name || !x.Sub[0].Equal(y.Sub[0]) {
 			return false
 		}
+
+	case OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind, OpAtomic:
+		if !x.Sub[0].Equal(y.Sub[0]) {
+			return false
+		}
 	}
 	return true
 }
@@ -206,8 +222,25 @@ func writeRegexp(b *strings.Builder, re *Regexp) {
 			writeRegexp(b, re.Sub[0])
 		}
 		b.WriteRune(')')
+	case OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind, OpAtomic:
+		switch re.Op {
+		case OpLookahead:
+			b.WriteString(`(?=`)
+		case OpNegLookahead:
+			b.WriteString(`(?!`)
+		case OpLookbehind:
+			b.WriteString(`(?<=`)
+		case OpNegLookbehind:
+			b.WriteString(`(?<!`)
+		case OpAtomic:
+			b.WriteString(`(?>`)
+		}
+		if re.Sub[0].Op != OpEmptyMatch {
+			writeRegexp(b, re.Sub[0])
+		}
+		b.WriteRune(')')
 	case OpStar, OpPlus, OpQuest, OpRepeat:
-		if sub := re.Sub[0]; sub.Op > OpCapture || sub.Op == OpLiteral && len(sub.Rune) > 1 {
+		if sub := re.Sub[0]; sub.Op > OpCapture && !isLookaround(sub.Op) && sub.Op != OpAtomic || sub.Op == OpLiteral && len(sub.Rune) > 1 {
 			b.WriteString(`(?:`)
 			writeRegexp(b, sub)
 			b.WriteString(`)`)
@@ -330,3 +363,25 @@ func (re *Regexp) capNames(names []string) {
 		sub.capNames(names)
 	}
 }
+
+// HasOp walks the regexp to find whether it uses any of ops.
+func (re *Regexp) HasOp(ops ...Op) bool {
+	for _, op := range ops {
+		if re.Op == op {
+			return true
+		}
+	}
+	for _, sub := range re.Sub {
+		if sub.HasOp(ops...) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsBacktrack reports whether the regexp has backreferences,
+// lookaround assertions or atomic groups, which only a backtracking
+// matcher runs exactly.
+func (re *Regexp) NeedsBacktrack() bool {
+	return re.HasOp(OpBackref, OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind, OpAtomic)
+}
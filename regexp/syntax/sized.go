@@ -1,120 +1,188 @@
 package syntax
 
-// sizedRegexp is a regular expression with bounded length.
-type sizedRegexp struct {
-	sizes    []*Regexp // len: max - min
+import "strconv"
+
+// A SizedRegexp is a regular expression split by the length of the
+// strings that it matches. It covers the lengths in [min, max) and
+// holds, for each length, a regexp without repetitions or captures
+// that matches exactly the strings of that length.
+type SizedRegexp struct {
+	sizes    []*Regexp // len: max - min; nil for no match
 	min, max int       // min <= max
 }
 
-func newSizedRegexp(min, max int) *sizedRegexp {
-	return &sizedRegexp{make([]*Regexp, max-min), min, max}
+func newSizedRegexp(min, max int) *SizedRegexp {
+	return &SizedRegexp{make([]*Regexp, max-min), min, max}
+}
+
+// Min returns the least length covered by s.
+func (s *SizedRegexp) Min() int {
+	return s.min
+}
+
+// Max returns one more than the greatest length covered by s.
+func (s *SizedRegexp) Max() int {
+	return s.max
 }
 
-func (s *sizedRegexp) inBounds(size int) bool {
+func (s *SizedRegexp) inBounds(size int) bool {
 	return s.min <= size && size < s.max
 }
 
-func (s *sizedRegexp) size(n int) *Regexp {
-	if !s.inBounds(n) {
+// Size returns a regexp matching the strings of length n. It returns
+// a regexp that matches nothing if there are none or if n is out of
+// bounds.
+func (s *SizedRegexp) Size(n int) *Regexp {
+	if !s.inBounds(n) || s.sizes[n-s.min] == nil {
 		return &Regexp{Op: OpNoMatch}
 	}
 	return s.sizes[n-s.min]
 }
 
-func (s *sizedRegexp) regexp() *Regexp {
-	if s.min+1 == s.max {
-		return s.sizes[0]
+// Lengths returns, in increasing order, the lengths in bounds for
+// which some string matches.
+func (s *SizedRegexp) Lengths() []int {
+	var lengths []int
+	for i, re := range s.sizes {
+		if re != nil {
+			lengths = append(lengths, s.min+i)
+		}
 	}
-	return &Regexp{Op: OpAlternate, Sub: s.sizes}
+	return lengths
 }
 
-func (s *sizedRegexp) trim(min, max int) *sizedRegexp {
-	if max < min || max < s.min || min > s.max {
+// Regexp returns a regexp matching the strings of every length in
+// bounds.
+func (s *SizedRegexp) Regexp() *Regexp {
+	var subs []*Regexp
+	for _, re := range s.sizes {
+		if re != nil {
+			subs = append(subs, re)
+		}
+	}
+	switch len(subs) {
+	case 0:
+		return &Regexp{Op: OpNoMatch}
+	case 1:
+		return subs[0]
+	}
+	return &Regexp{Op: OpAlternate, Sub: subs}
+}
+
+func (s *SizedRegexp) trim(min, max int) *SizedRegexp {
+	if max < min {
 		panic("regexp: invalid trim bounds")
 	}
-	t := &sizedRegexp{s.sizes, s.min, s.max}
-	if min > t.min {
-		t.sizes = t.sizes[min-t.min:]
-		t.min = min
+	if min < s.min {
+		min = s.min
+	}
+	if max > s.max {
+		max = s.max
 	}
-	if max < t.max {
-		t.sizes = t.sizes[:t.max-max]
-		t.max = max
+	if min >= max {
+		return &SizedRegexp{nil, 0, 0}
 	}
-	return t
+	return &SizedRegexp{s.sizes[min-s.min : max-s.min], min, max}
 }
 
-func (s *sizedRegexp) insert(re *Regexp, size int) {
+// insert adds re as an alternative for strings of the given size.
+// Existing alternations are copied rather than appended to, because
+// they may be shared with other sized regexps.
+func (s *SizedRegexp) insert(re *Regexp, size int) {
 	if !s.inBounds(size) {
 		panic("regexp: invalid insert size")
 	}
 	i := size - s.min
-	if s.sizes[i] == nil {
+	old := s.sizes[i]
+	if old == nil {
 		s.sizes[i] = re
+		return
+	}
+	nre := &Regexp{Op: OpAlternate}
+	if old.Op == OpAlternate {
+		nre.Sub = append(nre.Sub0[:0], old.Sub...)
 	} else {
-		if s.sizes[i].Op != OpAlternate {
-			nre := &Regexp{Op: OpAlternate}
-			nre.Sub = append(re.Sub0[:0], s.sizes[i])
-			s.sizes[i] = nre
-		}
-		if re.Op == OpAlternate {
-			s.sizes[i].Sub = append(s.sizes[i].Sub, re.Sub...)
-		} else {
-			s.sizes[i].Sub = append(s.sizes[i].Sub, re)
+		nre.Sub = append(nre.Sub0[:0], old)
+	}
+	subs := []*Regexp{re}
+	if re.Op == OpAlternate {
+		subs = re.Sub
+	}
+Subs:
+	for _, sub := range subs {
+		for _, alt := range nre.Sub {
+			if alt.Equal(sub) {
+				continue Subs
+			}
 		}
+		nre.Sub = append(nre.Sub, sub)
 	}
+	s.sizes[i] = nre
 }
 
-func concat(a, b *sizedRegexp, min, max int) *sizedRegexp {
-	cMin, cMax := a.min+b.min, a.max+b.max
-	if cMin >= max {
-		return &sizedRegexp{nil, 0, 0}
-	}
+func concat(a, b *SizedRegexp, min, max int) *SizedRegexp {
+	cMin, cMax := a.min+b.min, a.max+b.max-1
 	if max < cMax {
 		cMax = max
 	}
+	if cMin >= cMax {
+		return &SizedRegexp{nil, 0, 0}
+	}
 	c := newSizedRegexp(cMin, cMax)
 	for i, aRe := range a.sizes {
-		if aRe != nil {
-			for j, bRe := range b.sizes {
-				if bRe != nil {
-					n := i + j + cMin
-					if n < cMax {
-						if aRe.Op == OpEmptyMatch || bRe.Op == OpNoMatch {
-							c.insert(bRe, n)
-						} else if bRe.Op == OpEmptyMatch || aRe.Op == OpNoMatch {
-							c.insert(aRe, n)
-						} else if aRe.Op == OpLiteral && bRe.Op == OpLiteral {
-							ab := &Regexp{Op: OpLiteral}
-							ab.Rune = append(ab.Rune, aRe.Rune...)
-							ab.Rune = append(ab.Rune, bRe.Rune...)
-							c.insert(ab, n)
-						} else {
-							ab := &Regexp{Op: OpConcat}
-							ab.Sub = ab.Sub0[:0]
-
-							if aRe.Op == OpConcat {
-								ab.Sub = append(ab.Sub, aRe.Sub...)
-							} else {
-								ab.Sub = append(ab.Sub, aRe)
-							}
-							if bRe.Op == OpConcat {
-								ab.Sub = append(ab.Sub, bRe.Sub...)
-							} else {
-								ab.Sub = append(ab.Sub, bRe)
-							}
-
-							c.insert(ab, n)
-						}
-					}
-				}
+		if aRe == nil {
+			continue
+		}
+		for j, bRe := range b.sizes {
+			if bRe == nil {
+				continue
+			}
+			n := i + j + cMin
+			if n >= cMax {
+				break
 			}
+			c.insert(concat2(aRe, bRe), n)
 		}
 	}
 	return c.trim(min, max)
 }
 
-func union(a, b *sizedRegexp, min, max int) *sizedRegexp {
+// concat2 returns the concatenation of a and b, flattening
+// nested concatenations and merging adjacent literals.
+func concat2(a, b *Regexp) *Regexp {
+	switch {
+	case a.Op == OpEmptyMatch:
+		return b
+	case b.Op == OpEmptyMatch:
+		return a
+	case a.Op == OpLiteral && b.Op == OpLiteral && a.Flags&FoldCase == b.Flags&FoldCase:
+		ab := &Regexp{Op: OpLiteral, Flags: a.Flags}
+		ab.Rune = append(ab.Rune0[:0], a.Rune...)
+		ab.Rune = append(ab.Rune, b.Rune...)
+		return ab
+	}
+	ab := &Regexp{Op: OpConcat}
+	ab.Sub = ab.Sub0[:0]
+	if a.Op == OpConcat {
+		ab.Sub = append(ab.Sub, a.Sub...)
+	} else {
+		ab.Sub = append(ab.Sub, a)
+	}
+	if b.Op == OpConcat {
+		ab.Sub = append(ab.Sub, b.Sub...)
+	} else {
+		ab.Sub = append(ab.Sub, b)
+	}
+	return ab
+}
+
+func union(a, b *SizedRegexp, min, max int) *SizedRegexp {
+	if a.min == a.max {
+		return b.trim(min, max)
+	}
+	if b.min == b.max {
+		return a.trim(min, max)
+	}
 	cMin, cMax := a.min, a.max
 	if b.min < a.min {
 		cMin = b.min
@@ -128,6 +196,9 @@ func union(a, b *sizedRegexp, min, max int) *sizedRegexp {
 	if max < cMax {
 		cMax = max
 	}
+	if cMin >= cMax {
+		return &SizedRegexp{nil, 0, 0}
+	}
 
 	c := newSizedRegexp(cMin, cMax)
 	for i, aRe := range a.sizes {
@@ -143,30 +214,36 @@ func union(a, b *sizedRegexp, min, max int) *sizedRegexp {
 	return c
 }
 
-func plus(a *sizedRegexp, min, max int) *sizedRegexp {
-	aMin := a.min
-	if a.min == 0 { // cannot divide by zero
-		for i := 1; i < len(a.sizes); i++ {
-			if a.sizes[i] != nil {
-				aMin = a.min + i
-				goto Nonzero
-			}
-		}
+// plus returns one or more repetitions of a. Empty repetitions
+// are dropped, so that each repetition consumes at least one rune
+// and strings of length n are built from the shorter ones.
+func plus(a *SizedRegexp, min, max int) *SizedRegexp {
+	if max <= 1 {
 		return a.trim(min, max)
 	}
-Nonzero:
-
-	// TODO: concat using shared terms like exponentiation by squaring.
-	star := a
-	acc := a
-	n := (max + aMin - 1) / aMin
-	for i := 2; i < n; i++ {
-		acc = concat(acc, a, min, max)
-		star = union(star, acc, min, max)
+	star := newSizedRegexp(0, max)
+	if a.inBounds(0) {
+		star.sizes[0] = a.sizes[0]
+	}
+	for n := 1; n < max; n++ {
+		if a.inBounds(n) && a.sizes[n-a.min] != nil {
+			star.insert(a.sizes[n-a.min], n)
+		}
+		for i := 1; i < n; i++ {
+			if !a.inBounds(i) || a.sizes[i-a.min] == nil || star.sizes[n-i] == nil {
+				continue
+			}
+			star.insert(concat2(a.sizes[i-a.min], star.sizes[n-i]), n)
+		}
 	}
 	return star.trim(min, max)
 }
 
+// Reverse returns a regexp that matches the reverse of the strings
+// that re matches. Backreferences are left in place, so the result
+// is only exact for regexps without them. Lookaheads become
+// lookbehinds of the reversed assertion and vice versa. Atomic groups
+// become plain groups, which may match more strings.
 func (re *Regexp) Reverse() *Regexp {
 	if re == nil {
 		return nil
@@ -178,7 +255,7 @@ func (re *Regexp) Reverse() *Regexp {
 		OpCharClass, OpAnyCharNotNL, OpAnyChar:
 		return re
 	case OpLiteral:
-		nre := &Regexp{Op: OpLiteral, Rune: make([]rune, len(re.Rune))}
+		nre := &Regexp{Op: OpLiteral, Flags: re.Flags, Rune: make([]rune, len(re.Rune))}
 		for i, r := range re.Rune {
 			nre.Rune[len(re.Rune)-i-1] = r
 		}
@@ -187,6 +264,21 @@ func (re *Regexp) Reverse() *Regexp {
 		return re
 	case OpCapture, OpStar, OpPlus, OpQuest, OpRepeat:
 		return re.transform1((*Regexp).Reverse)
+	case OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind:
+		nre := &Regexp{Flags: re.Flags, Sub: []*Regexp{re.Sub[0].Reverse()}}
+		switch re.Op {
+		case OpLookahead:
+			nre.Op = OpLookbehind
+		case OpNegLookahead:
+			nre.Op = OpNegLookbehind
+		case OpLookbehind:
+			nre.Op = OpLookahead
+		case OpNegLookbehind:
+			nre.Op = OpNegLookahead
+		}
+		return nre
+	case OpAtomic:
+		return re.Sub[0].Reverse()
 	case OpConcat:
 		if len(re.Sub) == 1 {
 			return re.Sub[0].Reverse()
@@ -203,6 +295,8 @@ func (re *Regexp) Reverse() *Regexp {
 	}
 }
 
+// Mask returns a regexp that matches the strings of re made up only
+// of runes in the character class runes, a sorted list of range pairs.
 func (re *Regexp) Mask(runes []rune) *Regexp {
 	if re == nil {
 		return nil
@@ -222,6 +316,9 @@ func (re *Regexp) Mask(runes []rune) *Regexp {
 	case OpAnyChar:
 		return &Regexp{Op: OpCharClass, Rune: runes}
 	case OpLiteral:
+		if re.Flags&FoldCase != 0 {
+			return re.maskFold(runes)
+		}
 	LiteralLoop:
 		for _, r := range re.Rune {
 			for i := 0; i < len(runes); i += 2 {
@@ -229,7 +326,7 @@ func (re *Regexp) Mask(runes []rune) *Regexp {
 				if lo <= r && r <= hi {
 					continue LiteralLoop
 				}
-				if r > hi {
+				if r < lo {
 					break
 				}
 			}
@@ -238,15 +335,44 @@ func (re *Regexp) Mask(runes []rune) *Regexp {
 		return re
 	case OpBackref:
 		return re
-	case OpCapture, OpStar, OpPlus, OpQuest, OpRepeat:
+	case OpCapture, OpStar, OpPlus, OpQuest, OpRepeat,
+		OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind, OpAtomic:
 		return re.transform1(maskFn)
 	case OpConcat, OpAlternate:
 		return re.transform(maskFn)
 	default:
-		panic("regexp: unhandled case in reverse")
+		panic("regexp: unhandled case in mask")
 	}
 }
 
+// maskFold masks a literal that ignores case, matching each of its
+// runes by those of its cases in runes.
+func (re *Regexp) maskFold(runes []rune) *Regexp {
+	subs := make([]*Regexp, len(re.Rune))
+	same := true
+	for i, r := range re.Rune {
+		cases := appendFoldedRange(nil, r, r)
+		cases = cleanClass(&cases)
+		class := intersectCharClass(cases, runes)
+		switch {
+		case len(class) == 0:
+			return &Regexp{Op: OpNoMatch}
+		case len(class) == 2 && class[0] == class[1]:
+			subs[i] = &Regexp{Op: OpLiteral, Rune: class[:1]}
+		default:
+			subs[i] = &Regexp{Op: OpCharClass, Rune: class}
+		}
+		same = same && equalRunes(class, cases)
+	}
+	switch {
+	case same:
+		return re
+	case len(subs) == 1:
+		return subs[0]
+	}
+	return &Regexp{Op: OpConcat, Sub: subs}
+}
+
 func intersectCharClass(c1, c2 []rune) []rune {
 	if len(c1) == 0 || len(c2) == 0 {
 		return nil
@@ -279,10 +405,10 @@ func intersectCharClass(c1, c2 []rune) []rune {
 			j += 2
 		case lo1 < lo2 && hi1 < hi2:
 			c3 = append(c3, lo2, hi1)
-			i++
+			i += 2
 		case lo2 < lo1 && hi2 < hi1:
 			c3 = append(c3, lo1, hi2)
-			j++
+			j += 2
 		default:
 			panic("regexp: unhandled case in intersectCharClass")
 		}
@@ -321,95 +447,322 @@ func (re *Regexp) transform(fn func(*Regexp) *Regexp) *Regexp {
 }
 
 type constrainer struct {
-	s        map[*Regexp]*sizedRegexp
-	captures []*sizedRegexp
+	s   map[*Regexp]*SizedRegexp
+	max int
 }
 
-// on interval [min, max)
-// min <= retmin < retmax <= max
-func (re *Regexp) constrainLength(min, max int) *sizedRegexp {
-	c := constrainer{make(map[*Regexp]*sizedRegexp), nil}
-	return c.constrain(re, min, max)
+// ConstrainLength splits re by the length of the strings that it
+// matches, for the lengths in [min, max). The regexp must be simplified
+// (returned from re.Simplify). Backreferences are approximated as by
+// expandBackrefs, so for regexps with backreferences the result may
+// match more strings than re, but no fewer. Lookaround assertions match
+// no runes and are kept, with their subexpressions constrained to
+// every length in bounds. Atomic groups, including possessive
+// repetitions, are approximated by their subexpressions, which may
+// match more strings once backtracking into them is allowed.
+func ConstrainLength(re *Regexp, min, max int) (*SizedRegexp, error) {
+	if min < 0 || max < min {
+		return nil, &Error{ErrInvalidLengthBounds, strconv.Itoa(min) + "," + strconv.Itoa(max)}
+	}
+	c := constrainer{make(map[*Regexp]*SizedRegexp), max}
+	s, err := c.constrain(expandBackrefs(re))
+	if err != nil {
+		return nil, err
+	}
+	if min == max {
+		return &SizedRegexp{nil, min, max}, nil
+	}
+	t := s.trim(min, max)
+	sized := newSizedRegexp(min, max)
+	for i, re := range t.sizes {
+		sized.sizes[t.min-min+i] = re
+	}
+	return sized, nil
 }
 
-func (c *constrainer) constrain(re *Regexp, min, max int) *sizedRegexp {
+// constrain returns the sized regexp for re on the interval [0, c.max).
+// Results are memoized by node, which is valid because c.max is fixed.
+func (c *constrainer) constrain(re *Regexp) (*SizedRegexp, error) {
 	if s, ok := c.s[re]; ok {
-		return s.trim(min, max)
+		return s, nil
 	}
-	var s *sizedRegexp
+	var s *SizedRegexp
+	max := c.max
 
 	switch re.Op {
-	case OpNoMatch, // TODO: should OpNoMatch have a special case?
-		OpEmptyMatch,
+	case OpNoMatch:
+		s = &SizedRegexp{nil, 0, 0}
+	case OpEmptyMatch,
 		OpBeginLine, OpEndLine, OpBeginText, OpEndText,
 		OpWordBoundary, OpNoWordBoundary:
-		s = &sizedRegexp{[]*Regexp{re}, 0, 1}
+		s = &SizedRegexp{[]*Regexp{re}, 0, 1}
 	case OpCharClass, OpAnyCharNotNL, OpAnyChar:
-		if min > 1 || max <= 1 {
-			s = &sizedRegexp{nil, 0, 0}
+		if max <= 1 || re.Op == OpCharClass && len(re.Rune) == 0 {
+			s = &SizedRegexp{nil, 0, 0}
 			break
 		}
-		s = &sizedRegexp{[]*Regexp{re}, 1, 2}
+		s = &SizedRegexp{[]*Regexp{re}, 1, 2}
 	case OpLiteral:
-		if min > len(re.Rune) || max <= len(re.Rune) {
-			s = &sizedRegexp{nil, 0, 0}
+		if max <= len(re.Rune) {
+			s = &SizedRegexp{nil, 0, 0}
 			break
 		}
-		s = &sizedRegexp{[]*Regexp{re}, len(re.Rune), len(re.Rune) + 1}
+		s = &SizedRegexp{[]*Regexp{re}, len(re.Rune), len(re.Rune) + 1}
 	case OpCapture:
-		capture := c.constrain(re.Sub[0], min, max)
-		c.captures = append(c.captures, capture)
+		capture, err := c.constrain(re.Sub[0])
+		if err != nil {
+			return nil, err
+		}
 		s = capture
 	case OpBackref:
-		if re.Cap > len(c.captures) || re.Cap <= 0 {
-			panic("regexp: capture not found")
-		}
-		s = c.captures[re.Cap-1]
-	case OpStar:
-		sub := c.constrain(re.Sub[0], 0, max)
-		acc := plus(sub, min, max)
-		if min == 0 {
-			empty := &sizedRegexp{[]*Regexp{&Regexp{Op: OpEmptyMatch}}, 0, 1}
-			acc = union(acc, empty, min, max)
-		}
-		s = acc.trim(min, max)
-	case OpPlus:
-		sub := c.constrain(re.Sub[0], 0, max)
-		acc := plus(sub, min, max)
-		s = acc.trim(min, max)
-	case OpQuest:
-		sub := c.constrain(re.Sub[0], min, max)
-		if min == 0 {
-			empty := &sizedRegexp{[]*Regexp{&Regexp{Op: OpEmptyMatch}}, 0, 1}
-			sub = union(sub, empty, min, max)
+		// Backreferences to groups in re have been expanded.
+		return nil, &Error{ErrMissingCapture, re.String()}
+	case OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind:
+		sub, err := c.constrain(re.Sub[0])
+		if err != nil {
+			return nil, err
 		}
-		s = sub
-	case OpConcat:
-		if len(re.Sub) == 0 {
-			s = &sizedRegexp{[]*Regexp{&Regexp{Op: OpEmptyMatch}}, 0, 1}
+		nre := sub.Regexp()
+		switch {
+		case nre.Op != OpNoMatch:
+			nre = &Regexp{Op: re.Op, Sub: []*Regexp{nre}}
+		case re.Op == OpNegLookahead || re.Op == OpNegLookbehind:
+			nre = &Regexp{Op: OpEmptyMatch}
+		}
+		if nre.Op == OpNoMatch {
+			s = &SizedRegexp{nil, 0, 0}
 			break
 		}
-		acc := c.constrain(re.Sub[0], 0, max)
-		for _, sub := range re.Sub[1:] {
-			acc = concat(acc, c.constrain(sub, 0, max-acc.min), min, max)
+		s = &SizedRegexp{[]*Regexp{nre}, 0, 1}
+	case OpAtomic:
+		sub, err := c.constrain(re.Sub[0])
+		if err != nil {
+			return nil, err
 		}
-		s = acc.trim(min, max)
-	case OpAlternate:
+		s = sub
+	case OpStar, OpPlus, OpQuest:
+		sub, err := c.constrain(re.Sub[0])
+		if err != nil {
+			return nil, err
+		}
+		if re.Op != OpQuest {
+			sub = plus(sub, 0, max)
+		}
+		if re.Op != OpPlus {
+			empty := &SizedRegexp{[]*Regexp{&Regexp{Op: OpEmptyMatch}}, 0, 1}
+			sub = union(sub, empty, 0, max)
+		}
+		s = sub
+	case OpConcat, OpAlternate:
 		if len(re.Sub) == 0 {
-			s = &sizedRegexp{nil, 0, 0}
+			if re.Op == OpConcat {
+				s = &SizedRegexp{[]*Regexp{&Regexp{Op: OpEmptyMatch}}, 0, 1}
+			} else {
+				s = &SizedRegexp{nil, 0, 0}
+			}
 			break
 		}
-		acc := c.constrain(re.Sub[0], min, max)
-		for _, sub := range re.Sub[1:] {
-			acc = union(acc, c.constrain(sub, min, max), min, max)
+		for i, sub := range re.Sub {
+			t, err := c.constrain(sub)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case i == 0:
+				s = t
+			case re.Op == OpConcat:
+				s = concat(s, t, 0, max)
+			default:
+				s = union(s, t, 0, max)
+			}
 		}
-		s = acc
 	case OpRepeat:
-		panic("regexp: repeat not simplified")
+		return nil, &Error{ErrUnsimplifiedRepeat, re.String()}
 	default:
-		panic("regexp: unhandled case in constrain")
+		return nil, &Error{ErrInternalError, re.String()}
 	}
 
 	c.s[re] = s
-	return s
+	return s, nil
+}
+
+// A backrefExpander replaces backreferences by the strings that their
+// groups may have matched.
+type backrefExpander struct {
+	groups  map[int]*Regexp // capture nodes, by index
+	outer   map[int][]int   // indexes of the groups around each group
+	open    map[int]bool    // groups that the walk is inside
+	enclose []int           // groups around the walk, outermost first
+}
+
+// expandBackrefs returns re with each backreference replaced by a copy
+// of the group that it refers to, so that the result matches every
+// string that re does. The copy drops the group's assertions, which
+// held where the group matched rather than where the reference is,
+// and matches its runes in every case if the reference ignores case.
+// The copy of a group that has surely matched before the reference is
+// used as is. One that may not have matched, because it is in an
+// alternative, an optional repetition, a lookaround or an atomic group
+// that may have been skipped, is made optional, as an unmatched group
+// matches the empty string. A reference inside its own group matches
+// the empty string. Backreferences in the copies are expanded the same
+// way, taking no group outside the copied one to have matched.
+// References to missing groups are left in place.
+func expandBackrefs(re *Regexp) *Regexp {
+	if !re.HasOp(OpBackref) {
+		return re
+	}
+	e := &backrefExpander{
+		groups: make(map[int]*Regexp),
+		outer:  make(map[int][]int),
+		open:   make(map[int]bool),
+	}
+	e.collect(re)
+	nre, _ := e.expand(re, nil)
+	return nre
+}
+
+// collect records the capture nodes of re and the groups around them.
+func (e *backrefExpander) collect(re *Regexp) {
+	if re.Op == OpCapture {
+		e.groups[re.Cap] = re
+		e.outer[re.Cap] = append([]int(nil), e.enclose...)
+		e.enclose = append(e.enclose, re.Cap)
+		defer func() { e.enclose = e.enclose[:len(e.enclose)-1] }()
+	}
+	for _, sub := range re.Sub {
+		e.collect(sub)
+	}
+}
+
+// expand expands the backreferences of re, before which the groups in
+// set have surely matched, and returns it with the groups that have
+// surely matched after it. Sets are not modified in place.
+func (e *backrefExpander) expand(re *Regexp, set map[int]bool) (*Regexp, map[int]bool) {
+	switch re.Op {
+	case OpBackref:
+		group, ok := e.groups[re.Cap]
+		if !ok {
+			return re, set
+		}
+		if e.open[re.Cap] {
+			return &Regexp{Op: OpEmptyMatch}, set
+		}
+		sub := groupText(e.copyGroup(group), re.Flags&FoldCase != 0)
+		if !set[re.Cap] {
+			sub = &Regexp{Op: OpQuest, Sub: []*Regexp{sub}}
+		}
+		return sub, set
+	case OpCapture:
+		open := e.open[re.Cap]
+		e.open[re.Cap] = true
+		sub, after := e.expand(re.Sub[0], set)
+		e.open[re.Cap] = open
+		return re.withSubs(sub), addCap(after, re.Cap)
+	case OpConcat:
+		subs := make([]*Regexp, len(re.Sub))
+		for i, sub := range re.Sub {
+			subs[i], set = e.expand(sub, set)
+		}
+		return re.withSubs(subs...), set
+	case OpAlternate:
+		subs := make([]*Regexp, len(re.Sub))
+		var after map[int]bool
+		for i, sub := range re.Sub {
+			var alt map[int]bool
+			subs[i], alt = e.expand(sub, set)
+			if i == 0 {
+				after = alt
+				continue
+			}
+			both := make(map[int]bool)
+			for cap := range after {
+				if alt[cap] {
+					both[cap] = true
+				}
+			}
+			after = both
+		}
+		return re.withSubs(subs...), after
+	case OpPlus, OpAtomic:
+		sub, after := e.expand(re.Sub[0], set)
+		return re.withSubs(sub), after
+	case OpRepeat:
+		sub, after := e.expand(re.Sub[0], set)
+		if re.Min == 0 {
+			after = set
+		}
+		return re.withSubs(sub), after
+	}
+	// Star, quest and lookarounds may be passed over without
+	// matching the groups in them.
+	subs := make([]*Regexp, len(re.Sub))
+	for i, sub := range re.Sub {
+		subs[i], _ = e.expand(sub, set)
+	}
+	return re.withSubs(subs...), set
+}
+
+// copyGroup returns the expanded subexpression of group, taking the
+// groups around it to be open and no others to have matched.
+func (e *backrefExpander) copyGroup(group *Regexp) *Regexp {
+	open := e.open
+	e.open = map[int]bool{group.Cap: true}
+	for _, cap := range e.outer[group.Cap] {
+		e.open[cap] = true
+	}
+	sub, _ := e.expand(group.Sub[0], nil)
+	e.open = open
+	return sub
+}
+
+// groupText returns a regexp for the strings that re, a copy of a
+// group, may have matched: its assertions are dropped and, with fold,
+// its literals and classes match their runes in every case.
+func groupText(re *Regexp, fold bool) *Regexp {
+	switch re.Op {
+	case OpBeginLine, OpEndLine, OpBeginText, OpEndText,
+		OpWordBoundary, OpNoWordBoundary,
+		OpLookahead, OpNegLookahead, OpLookbehind, OpNegLookbehind:
+		return &Regexp{Op: OpEmptyMatch}
+	case OpLiteral:
+		if !fold || re.Flags&FoldCase != 0 {
+			return re
+		}
+		return &Regexp{Op: OpLiteral, Flags: re.Flags | FoldCase, Rune: re.Rune}
+	case OpCharClass:
+		if !fold {
+			return re
+		}
+		class := appendFoldedClass(nil, re.Rune)
+		return &Regexp{Op: OpCharClass, Flags: re.Flags, Rune: cleanClass(&class)}
+	}
+	if len(re.Sub) == 0 {
+		return re
+	}
+	return re.transform(func(sub *Regexp) *Regexp {
+		return groupText(sub, fold)
+	})
+}
+
+// addCap returns set with cap added.
+func addCap(set map[int]bool, cap int) map[int]bool {
+	if set[cap] {
+		return set
+	}
+	nset := map[int]bool{cap: true}
+	for c := range set {
+		nset[c] = true
+	}
+	return nset
+}
+
+// withSubs returns re with its subexpressions replaced by subs, or re
+// itself if they are unchanged.
+func (re *Regexp) withSubs(subs ...*Regexp) *Regexp {
+	i := 0
+	return re.transform(func(*Regexp) *Regexp {
+		i++
+		return subs[i-1]
+	})
 }
@@ -0,0 +1,196 @@
+package syntax
+
+import (
+	"reflect"
+	"testing"
+)
+
+var constrainLengthTests = []struct {
+	Regexp string
+	Length int
+	Fixed  string
+}{
+	{`a*`, 0, `(?:)`},
+	{`a*`, 3, `aaa`},
+	{`a{2,3}b`, 2, `[^\x00-\x{10FFFF}]`},
+	{`a{2,3}b`, 4, `aaab`},
+	{`x?y?`, 1, `y|x`},
+	{`x?y?`, 2, `xy`},
+	{`x?y?`, 3, `[^\x00-\x{10FFFF}]`},
+	{`(a|bc)+`, 3, `a(?:bc|aa)|bca`},
+	{`(RR|HHH)*.?`, 4, `HHH(?-s:.)|RRRR`},
+	{`[AM]*CM(RC)*R?`, 4, `[AM]CMR|CMRC|[AM][AM]CM`},
+	{`(ND|ET|IN)[^X]*`, 3, `(?:ND|ET|IN)[^X]`},
+	{`(...?)\1*`, 4, `(?-s:.)(?-s:.)(?-s:.)(?-s:.)`},
+	{`P+(..)\1.*`, 4, `[^\x00-\x{10FFFF}]`},
+	{`(?=a+b)a*.`, 2, `(?=ab)a(?-s:.)`},
+	{`(?!x*)a`, 1, `(?!(?:)|x)a`},
+	{`(?<!x[^\x00-\x{10FFFF}])a`, 1, `a`},
+	{`(?>a*)a`, 2, `aa`},
+	{`b++(?>a|ab)`, 3, `bab|bba`},
+	{`(?:(a)|b)\1`, 1, `a|b`},
+	{`(?:(a)|b)\1`, 2, `(?:a|b)a`},
+	{`(a)|\1`, 0, `(?:)`},
+	{`(a)|\1`, 1, `a`},
+	{`(?>(a)|b)\1`, 1, `a|b`},
+	{`(a)?\1`, 0, `(?:)`},
+	{`(a\1)`, 1, `a`},
+	{`(a)+\1`, 2, `aa`},
+	{`(a)(?i)\1`, 2, `a(?i:a)`},
+	{`(\ba)\1`, 2, `\baa`},
+}
+
+func TestConstrainLength(t *testing.T) {
+	for _, tt := range constrainLengthTests {
+		re, err := Parse(tt.Regexp, Perl|Backref|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		sized, err := ConstrainLength(re.Simplify(), tt.Length, tt.Length+1)
+		if err != nil {
+			t.Errorf("ConstrainLength(%#q, %d) = error %v", tt.Regexp, tt.Length, err)
+			continue
+		}
+		if s := sized.Size(tt.Length).String(); s != tt.Fixed {
+			t.Errorf("ConstrainLength(%#q, %d) = %#q, want %#q", tt.Regexp, tt.Length, s, tt.Fixed)
+		}
+	}
+}
+
+func TestConstrainLengthBounds(t *testing.T) {
+	re, err := Parse(`(RR|HHH)*.?`, Perl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sized, err := ConstrainLength(re.Simplify(), 2, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sized.Min() != 2 || sized.Max() != 8 {
+		t.Errorf("bounds = [%d, %d), want [2, 8)", sized.Min(), sized.Max())
+	}
+	if got, want := sized.Lengths(), []int{2, 3, 4, 5, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lengths() = %v, want %v", got, want)
+	}
+	if s := sized.Size(9).String(); s != `[^\x00-\x{10FFFF}]` {
+		t.Errorf("Size(9) = %#q, want no match", s)
+	}
+
+	re, err = Parse(`[AB]{3}`, Perl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sized, err = ConstrainLength(re.Simplify(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sized.Lengths(), []int{3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lengths() = %v, want %v", got, want)
+	}
+}
+
+func TestConstrainLengthErrors(t *testing.T) {
+	for _, tt := range []struct {
+		Regexp   string
+		Simplify bool
+		Min, Max int
+		Code     ErrorCode
+	}{
+		{`a{2}`, false, 0, 3, ErrUnsimplifiedRepeat},
+		{`a`, true, 3, 2, ErrInvalidLengthBounds},
+		{`a`, true, -1, 2, ErrInvalidLengthBounds},
+	} {
+		re, err := Parse(tt.Regexp, Perl|Backref)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		if tt.Simplify {
+			re = re.Simplify()
+		}
+		_, err = ConstrainLength(re, tt.Min, tt.Max)
+		if e, ok := err.(*Error); !ok || e.Code != tt.Code {
+			t.Errorf("ConstrainLength(%#q, %d, %d) = error %v, want %s", tt.Regexp, tt.Min, tt.Max, err, tt.Code)
+		}
+	}
+
+	// The parser drops references to missing groups, so build one.
+	re := &Regexp{Op: OpConcat, Sub: []*Regexp{{Op: OpLiteral, Rune: []rune{'a'}}, {Op: OpBackref, Cap: 1}}}
+	_, err := ConstrainLength(re, 0, 3)
+	if e, ok := err.(*Error); !ok || e.Code != ErrMissingCapture {
+		t.Errorf("ConstrainLength(%#q, 0, 3) = error %v, want %s", re, err, ErrMissingCapture)
+	}
+}
+
+var maskTests = []struct {
+	Regexp string
+	Mask   []rune
+	Masked string
+}{
+	{`[^X]`, []rune{'A', 'Z'}, `[A-WY-Z]`},
+	{`.`, []rune{'A', 'C', 'X', 'X'}, `[A-CX]`},
+	{`ABC`, []rune{'A', 'C'}, `ABC`},
+	{`ABX`, []rune{'A', 'C'}, `[^\x00-\x{10FFFF}]`},
+	{`[B-Y]*`, []rune{'A', 'C', 'X', 'Z'}, `[B-CX-Y]*`},
+	{`(?=[^X])(?<!BX)`, []rune{'A', 'C'}, `(?=[A-C])(?<![^\x00-\x{10FFFF}])`},
+	{`(?i)ab`, []rune{'A', 'B', 'a', 'b'}, `(?i:AB)`},
+	{`(?i)ab`, []rune{'a', 'z'}, `ab`},
+	{`(?i)kx`, []rune{'X', 'X', 'k', 'k', '\u212a', '\u212a'}, "[k\u212a]X"},
+	{`(?i)kx`, []rune{'a', 'w'}, `[^\x00-\x{10FFFF}]`},
+}
+
+func TestMask(t *testing.T) {
+	for _, tt := range maskTests {
+		re, err := Parse(tt.Regexp, Perl|Lookaround)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		s := re.Mask(tt.Mask).String()
+		if s != tt.Masked {
+			t.Errorf("Mask(%#q, %q) = %#q, want %#q", tt.Regexp, tt.Mask, s, tt.Masked)
+		}
+	}
+}
+
+func TestIntersectCharClass(t *testing.T) {
+	for _, tt := range []struct {
+		C1, C2, Want []rune
+	}{
+		{[]rune{'a', 'z'}, []rune{'c', 'e'}, []rune{'c', 'e'}},
+		{[]rune{'a', 'f'}, []rune{'d', 'k'}, []rune{'d', 'f'}},
+		{[]rune{'d', 'k'}, []rune{'a', 'f'}, []rune{'d', 'f'}},
+		{[]rune{'a', 'c', 'x', 'z'}, []rune{'b', 'y'}, []rune{'b', 'c', 'x', 'y'}},
+		{[]rune{'a', 'c', 'e', 'g'}, []rune{'c', 'e'}, []rune{'c', 'c', 'e', 'e'}},
+		{[]rune{'a', 'b'}, []rune{'x', 'y'}, nil},
+	} {
+		if got := intersectCharClass(tt.C1, tt.C2); !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("intersectCharClass(%q, %q) = %q, want %q", tt.C1, tt.C2, got, tt.Want)
+		}
+	}
+}
+
+var reverseTests = []struct {
+	Regexp   string
+	Reversed string
+}{
+	{`abc`, `cba`},
+	{`a(?:bc|d)*`, `(?:cb|d)*a`},
+	{`a(?=bc)`, `(?<=cb)a`},
+	{`(?<!a(?!b))c`, `c(?!(?<!b)a)`},
+	{`(?>ab)c`, `cba`},
+}
+
+func TestReverse(t *testing.T) {
+	for _, tt := range reverseTests {
+		re, err := Parse(tt.Regexp, Perl|Lookaround|Atomic)
+		if err != nil {
+			t.Errorf("Parse(%#q) = error %v", tt.Regexp, err)
+			continue
+		}
+		if s := re.Reverse().String(); s != tt.Reversed {
+			t.Errorf("Reverse(%#q) = %#q, want %#q", tt.Regexp, s, tt.Reversed)
+		}
+	}
+}